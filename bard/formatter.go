@@ -18,6 +18,7 @@ package bard
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/heroku/color"
 )
@@ -46,3 +47,26 @@ func FormatUserConfig(name string, reason string, def string) string {
 
 	return s
 }
+
+// FormatRejection formats a name and an optional description, and the reasons it was rejected in the form
+// '<b>name</b>[ description] rejected: reason[; reason]'.
+func FormatRejection(name string, description string, reasons []string) string {
+	return fmt.Sprintf("%s rejected: %s", FormatIdentity(name, description), strings.Join(reasons, "; "))
+}
+
+// FormatSize formats a size in bytes using binary units (e.g. 1.5 MiB).
+func FormatSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
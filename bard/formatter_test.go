@@ -58,4 +58,25 @@ func testFormatter(t *testing.T, context spec.G, it spec.S) {
 
 	})
 
+	context("FormatRejection", func() {
+
+		it("formats rejection", func() {
+			Expect(bard.FormatRejection("test-name", "test-description", []string{"test-reason-1", "test-reason-2"})).
+				To(Equal(fmt.Sprintf("%s test-description rejected: test-reason-1; test-reason-2",
+					color.New(color.Bold).Sprint("test-name"))))
+		})
+	})
+
+	context("FormatSize", func() {
+
+		it("formats bytes", func() {
+			Expect(bard.FormatSize(512)).To(Equal("512 B"))
+		})
+
+		it("formats binary units", func() {
+			Expect(bard.FormatSize(1536)).To(Equal("1.5 KiB"))
+			Expect(bard.FormatSize(3 * 1024 * 1024 * 1024)).To(Equal("3.0 GiB"))
+		})
+	})
+
 }
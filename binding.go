@@ -17,12 +17,26 @@
 package libpak
 
 import (
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/buildpacks/libcnb"
 )
 
+const (
+	// BindingType is the key of a Kubernetes Service Binding's type.  It is equivalent to libcnb.BindingKind.
+	BindingType = "type"
+
+	// ServiceBindingRootEnvironmentVariable is the environment variable that contains the path to the root of the
+	// Kubernetes Service Bindings.
+	ServiceBindingRootEnvironmentVariable = "SERVICE_BINDING_ROOT"
+)
+
 // BindingResolver provides functionality for resolving a binding given a collection of constraints.
 type BindingResolver struct {
 
@@ -30,13 +44,131 @@ type BindingResolver struct {
 	Bindings libcnb.Bindings
 }
 
+// NewBindingResolver creates a new instance that resolves against the bindings of a platform.  The bindings in
+// <platform>/bindings and in $SERVICE_BINDING_ROOT are read in either the CNB or Kubernetes Service Binding layout, with
+// those in $SERVICE_BINDING_ROOT taking precedence.
+func NewBindingResolver(platform libcnb.Platform) (BindingResolver, error) {
+	b := BindingResolver{Bindings: libcnb.Bindings{}}
+
+	for k, v := range platform.Bindings {
+		b.Bindings[k] = v
+	}
+
+	var roots []string
+	if platform.Path != "" {
+		roots = append(roots, filepath.Join(platform.Path, "bindings"))
+	}
+	if s, ok := os.LookupEnv(ServiceBindingRootEnvironmentVariable); ok && s != "" {
+		roots = append(roots, s)
+	}
+
+	for _, r := range roots {
+		bindings, err := NewBindingsFromPath(r)
+		if err != nil {
+			return BindingResolver{}, err
+		}
+
+		for k, v := range bindings {
+			b.Bindings[k] = v
+		}
+	}
+
+	return b, nil
+}
+
+// NewBindingsFromPath creates a new collection of bindings from all of the bindings at a given path.  A binding with
+// metadata or secret directories is read in the CNB layout.  Otherwise it is read in the Kubernetes Service Binding
+// layout, where each file is an entry: type is mapped to libcnb.BindingKind and provider to libcnb.BindingProvider in
+// the binding's metadata, and all other entries are added to its secret.  If the path does not exist, returns an empty
+// collection of bindings.
+func NewBindingsFromPath(path string) (libcnb.Bindings, error) {
+	bindings := libcnb.Bindings{}
+
+	files, err := ioutil.ReadDir(path)
+	if os.IsNotExist(err) {
+		return bindings, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to list bindings in %s: %w", path, err)
+	}
+
+	for _, f := range files {
+		file := filepath.Join(path, f.Name())
+
+		if strings.HasPrefix(f.Name(), ".") || !isDir(file) {
+			continue
+		}
+
+		if isDir(filepath.Join(file, "metadata")) || isDir(filepath.Join(file, "secret")) {
+			b, err := libcnb.NewBindingFromPath(file)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create new binding from %s: %w", file, err)
+			}
+
+			bindings[f.Name()] = b
+			continue
+		}
+
+		b, err := newServiceBindingFromPath(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create new binding from %s: %w", file, err)
+		}
+
+		bindings[f.Name()] = b
+	}
+
+	return bindings, nil
+}
+
+func newServiceBindingFromPath(path string) (libcnb.Binding, error) {
+	b := libcnb.NewBinding()
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return libcnb.Binding{}, fmt.Errorf("unable to list entries in %s: %w", path, err)
+	}
+
+	for _, f := range files {
+		// Hidden entries are the bookkeeping of Kubernetes projected volumes (e.g. ..data)
+		if strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+
+		file := filepath.Join(path, f.Name())
+		if isDir(file) {
+			continue
+		}
+
+		c, err := ioutil.ReadFile(file)
+		if err != nil {
+			return libcnb.Binding{}, fmt.Errorf("unable to read %s: %w", file, err)
+		}
+		v := strings.TrimSpace(string(c))
+
+		switch f.Name() {
+		case BindingType:
+			b.Metadata[libcnb.BindingKind] = v
+		case libcnb.BindingProvider:
+			b.Metadata[libcnb.BindingProvider] = v
+		default:
+			b.Secret[f.Name()] = string(c)
+		}
+	}
+
+	return b, nil
+}
+
+func isDir(path string) bool {
+	s, err := os.Stat(path)
+	return err == nil && s.IsDir()
+}
+
 // BindingConstraint is the collection of constraints to use during resolution.
 type BindingConstraint struct {
 
 	// Name is the name of the binding.
 	Name string
 
-	// Kind is the kind of the binding.
+	// Kind is the kind of the binding.  It matches either the CNB kind or the Kubernetes Service Binding type.
 	Kind string
 
 	// Provider is the provider of the binding.
@@ -46,23 +178,113 @@ type BindingConstraint struct {
 	Tags []string
 }
 
-// Resolve returns the matching binding within the collection of Bindings.  The candidate set is filtered by the
-// constraints.
-func (b *BindingResolver) Resolve(constraint BindingConstraint) (libcnb.Binding, bool, error) {
-	var m []libcnb.Binding
-	for name, binding := range b.Bindings {
-		if b.matches(name, binding, constraint) {
-			m = append(m, binding)
+// ResolvedBinding is a binding and its name.
+type ResolvedBinding struct {
+
+	// Name is the name of the binding.
+	Name string
+
+	// Binding is the binding.
+	Binding libcnb.Binding
+}
+
+// MultipleBindingsError is returned when more than one binding matches a constraint and a single binding is required.
+type MultipleBindingsError struct {
+
+	// Constraint is the constraint that the bindings match.
+	Constraint BindingConstraint
+
+	// Names are the names of the matching bindings.
+	Names []string
+}
+
+func (m MultipleBindingsError) Error() string {
+	return fmt.Sprintf("multiple bindings found for %+v: %s", m.Constraint, strings.Join(m.Names, ", "))
+}
+
+// BindingSelectionStrategy selects a single binding from multiple bindings that match a constraint.  Candidates are
+// sorted by name and there are always at least two of them.
+type BindingSelectionStrategy func(constraint BindingConstraint, candidates []ResolvedBinding) (ResolvedBinding, error)
+
+// RequireUnique is a BindingSelectionStrategy that returns a MultipleBindingsError.
+func RequireUnique(constraint BindingConstraint, candidates []ResolvedBinding) (ResolvedBinding, error) {
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.Name)
+	}
+
+	return ResolvedBinding{}, MultipleBindingsError{Constraint: constraint, Names: names}
+}
+
+// FirstByName is a BindingSelectionStrategy that selects the binding whose name sorts first.
+func FirstByName(_ BindingConstraint, candidates []ResolvedBinding) (ResolvedBinding, error) {
+	return candidates[0], nil
+}
+
+// PreferProvider creates a BindingSelectionStrategy that selects the binding with a given provider.  If no binding, or
+// more than one binding, has the provider, the selection is delegated to fallback.
+func PreferProvider(provider string, fallback BindingSelectionStrategy) BindingSelectionStrategy {
+	return func(constraint BindingConstraint, candidates []ResolvedBinding) (ResolvedBinding, error) {
+		var preferred []ResolvedBinding
+		for _, c := range candidates {
+			if c.Binding.Metadata[libcnb.BindingProvider] == provider {
+				preferred = append(preferred, c)
+			}
+		}
+
+		switch len(preferred) {
+		case 0:
+			return fallback(constraint, candidates)
+		case 1:
+			return preferred[0], nil
+		default:
+			return fallback(constraint, preferred)
 		}
 	}
+}
+
+// Resolve returns the matching binding within the collection of Bindings.  The candidate set is filtered by the
+// constraints.  If more than one binding matches, a MultipleBindingsError is returned.
+func (b *BindingResolver) Resolve(constraint BindingConstraint) (libcnb.Binding, bool, error) {
+	return b.ResolveWith(constraint, RequireUnique)
+}
+
+// ResolveWith returns the matching binding within the collection of Bindings.  The candidate set is filtered by the
+// constraints.  If more than one binding matches, the binding is selected by strategy.
+func (b *BindingResolver) ResolveWith(constraint BindingConstraint, strategy BindingSelectionStrategy) (libcnb.Binding, bool, error) {
+	m := b.ResolveAll(constraint)
 
 	if len(m) < 1 {
 		return libcnb.Binding{}, false, nil
 	} else if len(m) > 1 {
-		return libcnb.Binding{}, false, fmt.Errorf("multiple bindings found for %+v in %+v", constraint, b.Bindings)
+		r, err := strategy(constraint, m)
+		if err != nil {
+			return libcnb.Binding{}, false, err
+		}
+
+		return r.Binding, true, nil
+	}
+
+	return m[0].Binding, true, nil
+}
+
+// ResolveAll returns all of the matching bindings within the collection of Bindings, sorted by name.  The candidate
+// set is filtered by the constraints.
+func (b *BindingResolver) ResolveAll(constraint BindingConstraint) []ResolvedBinding {
+	var names []string
+	for name := range b.Bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var m []ResolvedBinding
+	for _, name := range names {
+		if b.matches(name, b.Bindings[name], constraint) {
+			m = append(m, ResolvedBinding{Name: name, Binding: b.Bindings[name]})
+		}
 	}
 
-	return m[0], true, nil
+	return m
 }
 
 func (BindingResolver) contains(candidates []string, value string) bool {
@@ -80,7 +302,8 @@ func (b BindingResolver) matches(name string, binding libcnb.Binding, constraint
 		return false
 	}
 
-	if constraint.Kind != "" && constraint.Kind != binding.Metadata[libcnb.BindingKind] {
+	if constraint.Kind != "" && constraint.Kind != binding.Metadata[libcnb.BindingKind] &&
+		constraint.Kind != binding.Metadata[BindingType] {
 		return false
 	}
 
@@ -97,3 +320,112 @@ func (b BindingResolver) matches(name string, binding libcnb.Binding, constraint
 
 	return true
 }
+
+// MissingBindingSecretsError is returned when a binding does not contain required secret keys.
+type MissingBindingSecretsError struct {
+
+	// Name is the name of the binding.
+	Name string
+
+	// Keys are the missing secret keys.
+	Keys []string
+}
+
+func (m MissingBindingSecretsError) Error() string {
+	return fmt.Sprintf("binding %s is missing required secret keys: %s", m.Name, strings.Join(m.Keys, ", "))
+}
+
+// BindingSecrets provides typed access to the secret of a binding.
+type BindingSecrets struct {
+
+	// Name is the name of the binding.
+	Name string
+
+	// Binding is the binding.
+	Binding libcnb.Binding
+}
+
+// NewBindingSecrets creates a new BindingSecrets for a binding.
+func NewBindingSecrets(name string, binding libcnb.Binding) BindingSecrets {
+	return BindingSecrets{Name: name, Binding: binding}
+}
+
+// Validate returns a MissingBindingSecretsError listing every key that does not exist in the secret.
+func (b BindingSecrets) Validate(keys ...string) error {
+	var missing []string
+	for _, k := range keys {
+		if _, ok := b.Binding.Secret[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+
+	if len(missing) > 0 {
+		return MissingBindingSecretsError{Name: b.Name, Keys: missing}
+	}
+
+	return nil
+}
+
+// Required returns the value of a required key.
+func (b BindingSecrets) Required(key string) (string, error) {
+	s, ok := b.Binding.Secret[key]
+	if !ok {
+		return "", MissingBindingSecretsError{Name: b.Name, Keys: []string{key}}
+	}
+
+	return s, nil
+}
+
+// LookupString returns the value of an optional key and whether it exists.
+func (b BindingSecrets) LookupString(key string) (string, bool) {
+	s, ok := b.Binding.Secret[key]
+	return s, ok
+}
+
+// StringOrDefault returns the value of an optional key, or def if it does not exist.
+func (b BindingSecrets) StringOrDefault(key string, def string) string {
+	if s, ok := b.Binding.Secret[key]; ok {
+		return s
+	}
+
+	return def
+}
+
+// Base64 returns the base64 decoded value of a required key.  Surrounding whitespace is ignored.
+func (b BindingSecrets) Base64(key string) ([]byte, error) {
+	s, err := b.Required(key)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode binding %s key %s: %w", b.Name, key, err)
+	}
+
+	return d, nil
+}
+
+// File writes the value of a required key to a file named key in dir and returns the path of that file.  The file is
+// readable only by its owner.
+func (b BindingSecrets) File(key string, dir string) (string, error) {
+	if key != filepath.Base(key) || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid binding %s key %s for file", b.Name, key)
+	}
+
+	s, err := b.Required(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+
+	file := filepath.Join(dir, key)
+	if err := ioutil.WriteFile(file, []byte(s), 0600); err != nil {
+		return "", fmt.Errorf("unable to write binding %s key %s to %s: %w", b.Name, key, file, err)
+	}
+
+	return file, nil
+}
@@ -17,7 +17,9 @@
 package libpak_test
 
 import (
-	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/buildpacks/libcnb"
@@ -47,9 +49,73 @@ func testBinding(t *testing.T, context spec.G, it spec.S) {
 		resolver.Bindings["test-binding-1"] = libcnb.NewBinding()
 		resolver.Bindings["test-binding-2"] = libcnb.NewBinding()
 
+		resolver.Bindings["test-binding-2"].Secret["test-key"] = "test-secret"
+
 		_, _, err := resolver.Resolve(libpak.BindingConstraint{})
-		Expect(err).To(MatchError(fmt.Errorf("multiple bindings found for %+v in %+v",
-			libpak.BindingConstraint{}, resolver.Bindings)))
+		Expect(err).To(MatchError(libpak.MultipleBindingsError{
+			Constraint: libpak.BindingConstraint{},
+			Names:      []string{"test-binding-1", "test-binding-2"},
+		}))
+		Expect(err).To(MatchError("multiple bindings found for {Name: Kind: Provider: Tags:[]}: test-binding-1, test-binding-2"))
+		Expect(err.Error()).NotTo(ContainSubstring("test-secret"))
+	})
+
+	context("multiple matches", func() {
+
+		it.Before(func() {
+			for _, name := range []string{"test-binding-3", "test-binding-1", "test-binding-2"} {
+				resolver.Bindings[name] = libcnb.NewBinding()
+				resolver.Bindings[name].Metadata[libcnb.BindingKind] = "test-kind"
+				resolver.Bindings[name].Metadata["test-key"] = name
+			}
+			resolver.Bindings["test-binding-2"].Metadata[libcnb.BindingProvider] = "test-provider"
+			resolver.Bindings["test-binding-3"].Metadata[libcnb.BindingProvider] = "test-provider"
+			resolver.Bindings["test-binding-4"] = libcnb.NewBinding()
+		})
+
+		it("resolves all bindings sorted by name", func() {
+			r := resolver.ResolveAll(libpak.BindingConstraint{Kind: "test-kind"})
+
+			Expect(r).To(HaveLen(3))
+			Expect(r[0].Name).To(Equal("test-binding-1"))
+			Expect(r[1].Name).To(Equal("test-binding-2"))
+			Expect(r[2].Name).To(Equal("test-binding-3"))
+			Expect(r[2].Binding).To(Equal(resolver.Bindings["test-binding-3"]))
+		})
+
+		it("selects first by name", func() {
+			b, ok, err := resolver.ResolveWith(libpak.BindingConstraint{Kind: "test-kind"}, libpak.FirstByName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(b.Metadata).To(HaveKeyWithValue("test-key", "test-binding-1"))
+		})
+
+		it("prefers provider", func() {
+			resolver.Bindings["test-binding-3"].Metadata[libcnb.BindingProvider] = "test-other-provider"
+
+			b, ok, err := resolver.ResolveWith(libpak.BindingConstraint{Kind: "test-kind"},
+				libpak.PreferProvider("test-provider", libpak.RequireUnique))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(b.Metadata).To(HaveKeyWithValue("test-key", "test-binding-2"))
+		})
+
+		it("falls back when multiple bindings have preferred provider", func() {
+			_, _, err := resolver.ResolveWith(libpak.BindingConstraint{Kind: "test-kind"},
+				libpak.PreferProvider("test-provider", libpak.RequireUnique))
+			Expect(err).To(MatchError(libpak.MultipleBindingsError{
+				Constraint: libpak.BindingConstraint{Kind: "test-kind"},
+				Names:      []string{"test-binding-2", "test-binding-3"},
+			}))
+		})
+
+		it("falls back when no binding has preferred provider", func() {
+			b, ok, err := resolver.ResolveWith(libpak.BindingConstraint{Kind: "test-kind"},
+				libpak.PreferProvider("test-unknown-provider", libpak.FirstByName))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(b.Metadata).To(HaveKeyWithValue("test-key", "test-binding-1"))
+		})
 	})
 
 	it("filters on name", func() {
@@ -115,4 +181,172 @@ func testBinding(t *testing.T, context spec.G, it spec.S) {
 		Expect(ok).To(BeTrue())
 		Expect(b).To(Equal(expected))
 	})
+
+	it("filters on Kubernetes Service Binding type", func() {
+		resolver.Bindings["test-binding-1"] = libcnb.NewBinding()
+		resolver.Bindings["test-binding-2"] = libcnb.NewBinding()
+		resolver.Bindings["test-binding-2"].Metadata[libpak.BindingType] = "test-kind"
+
+		b, ok, err := resolver.Resolve(libpak.BindingConstraint{Kind: "test-kind"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(b.Metadata).To(HaveKeyWithValue(libpak.BindingType, "test-kind"))
+	})
+
+	context("NewBindingResolver", func() {
+		var (
+			platformPath string
+			rootPath     string
+		)
+
+		it.Before(func() {
+			var err error
+
+			platformPath, err = ioutil.TempDir("", "binding-platform")
+			Expect(err).NotTo(HaveOccurred())
+
+			rootPath, err = ioutil.TempDir("", "binding-root")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("SERVICE_BINDING_ROOT")).To(Succeed())
+			Expect(os.RemoveAll(platformPath)).To(Succeed())
+			Expect(os.RemoveAll(rootPath)).To(Succeed())
+		})
+
+		write := func(path string, content string) {
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		it("reads both layouts", func() {
+			write(filepath.Join(platformPath, "bindings", "test-cnb", "metadata", "kind"), "test-kind-1")
+			write(filepath.Join(platformPath, "bindings", "test-cnb", "secret", "test-key"), "test-value-1")
+			write(filepath.Join(rootPath, "test-k8s", "type"), "test-kind-2\n")
+			write(filepath.Join(rootPath, "test-k8s", "provider"), "test-provider-2")
+			write(filepath.Join(rootPath, "test-k8s", "test-key"), "test-value-2")
+			write(filepath.Join(rootPath, "test-k8s", "..data", "test-key"), "test-value-2")
+			Expect(os.Setenv("SERVICE_BINDING_ROOT", rootPath)).To(Succeed())
+
+			r, err := libpak.NewBindingResolver(libcnb.Platform{
+				Bindings: libcnb.Bindings{"test-existing": libcnb.NewBinding()},
+				Path:     platformPath,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Bindings).To(Equal(libcnb.Bindings{
+				"test-existing": libcnb.NewBinding(),
+				"test-cnb": libcnb.Binding{
+					Metadata: map[string]string{libcnb.BindingKind: "test-kind-1"},
+					Secret:   map[string]string{"test-key": "test-value-1"},
+				},
+				"test-k8s": libcnb.Binding{
+					Metadata: map[string]string{libcnb.BindingKind: "test-kind-2", libcnb.BindingProvider: "test-provider-2"},
+					Secret:   map[string]string{"test-key": "test-value-2"},
+				},
+			}))
+
+			b, ok, err := r.Resolve(libpak.BindingConstraint{Kind: "test-kind-2", Provider: "test-provider-2"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(b.Secret).To(HaveKeyWithValue("test-key", "test-value-2"))
+		})
+
+		it("ignores missing paths", func() {
+			Expect(os.Setenv("SERVICE_BINDING_ROOT", filepath.Join(rootPath, "does-not-exist"))).To(Succeed())
+
+			r, err := libpak.NewBindingResolver(libcnb.Platform{Path: platformPath})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Bindings).To(BeEmpty())
+		})
+	})
+
+	context("BindingSecrets", func() {
+		var (
+			secrets libpak.BindingSecrets
+		)
+
+		it.Before(func() {
+			binding := libcnb.NewBinding()
+			binding.Secret["test-key"] = "test-value"
+			binding.Secret["test-base64"] = "dGVzdC1kZWNvZGVk\n"
+			binding.Secret["test-invalid-base64"] = "!!!"
+
+			secrets = libpak.NewBindingSecrets("test-binding", binding)
+		})
+
+		it("validates required keys", func() {
+			Expect(secrets.Validate("test-key", "test-base64")).To(Succeed())
+			Expect(secrets.Validate("test-key", "test-missing-1", "test-missing-2")).To(MatchError(
+				"binding test-binding is missing required secret keys: test-missing-1, test-missing-2"))
+		})
+
+		it("returns required strings", func() {
+			Expect(secrets.Required("test-key")).To(Equal("test-value"))
+
+			_, err := secrets.Required("test-missing")
+			Expect(err).To(MatchError(libpak.MissingBindingSecretsError{Name: "test-binding", Keys: []string{"test-missing"}}))
+		})
+
+		it("returns optional strings", func() {
+			s, ok := secrets.LookupString("test-key")
+			Expect(ok).To(BeTrue())
+			Expect(s).To(Equal("test-value"))
+
+			_, ok = secrets.LookupString("test-missing")
+			Expect(ok).To(BeFalse())
+
+			Expect(secrets.StringOrDefault("test-key", "test-default")).To(Equal("test-value"))
+			Expect(secrets.StringOrDefault("test-missing", "test-default")).To(Equal("test-default"))
+		})
+
+		it("decodes base64", func() {
+			Expect(secrets.Base64("test-base64")).To(Equal([]byte("test-decoded")))
+
+			_, err := secrets.Base64("test-invalid-base64")
+			Expect(err).To(MatchError(HavePrefix("unable to decode binding test-binding key test-invalid-base64")))
+
+			_, err = secrets.Base64("test-missing")
+			Expect(err).To(HaveOccurred())
+		})
+
+		context("file", func() {
+			var (
+				path string
+			)
+
+			it.Before(func() {
+				var err error
+				path, err = ioutil.TempDir("", "binding-secrets")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(path)).To(Succeed())
+			})
+
+			it("writes key to file", func() {
+				file, err := secrets.File("test-key", filepath.Join(path, "secrets"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(file).To(Equal(filepath.Join(path, "secrets", "test-key")))
+				Expect(ioutil.ReadFile(file)).To(Equal([]byte("test-value")))
+
+				info, err := os.Stat(file)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			})
+
+			it("returns error if key is missing", func() {
+				_, err := secrets.File("test-missing", path)
+				Expect(err).To(HaveOccurred())
+			})
+
+			it("returns error if key is invalid without creating directory", func() {
+				_, err := secrets.File("../test-key", filepath.Join(path, "secrets"))
+				Expect(err).To(MatchError("invalid binding test-binding key ../test-key for file"))
+				Expect(filepath.Join(path, "secrets")).NotTo(BeADirectory())
+			})
+		})
+	})
 }
@@ -17,29 +17,74 @@
 package libpak
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
 	"github.com/buildpacks/libcnb"
+	"github.com/heroku/color"
 	"github.com/paketo-buildpacks/libpak/bard"
 	"github.com/paketo-buildpacks/libpak/internal"
 )
 
-// Build is called by the main function of a buildpack, for build.
+// Build is called by the main function of a buildpack, for build.  If builder is a StaleLayerCleanup, layers that
+// were not touched by a LayerContributor during the build are removed once all layers have been contributed.
 func Build(builder libcnb.Builder, options ...libcnb.Option) {
-	libcnb.Build(buildDelegate{delegate: builder},
+	touchedLayers.reset()
+
+	delegate := &buildDelegate{delegate: builder, logger: bard.NewLogger(os.Stdout)}
+
+	libcnb.Build(delegate,
 		append([]libcnb.Option{
 			libcnb.WithEnvironmentWriter(internal.NewEnvironmentWriter()),
 			libcnb.WithExitHandler(internal.NewExitHandler()),
 			libcnb.WithTOMLWriter(internal.NewTOMLWriter()),
 		}, options...)...,
 	)
+
+	delegate.removeStaleLayers()
+}
+
+// StaleLayerCleanup is a libcnb.Builder that opts in to the removal of stale layers by Build.  A stale layer is a
+// layer directory left by a previous build that was not touched by a LayerContributor during this build.
+type StaleLayerCleanup struct {
+
+	// Builder is the builder to delegate to.
+	Builder libcnb.Builder
+
+	// KeepNonLaunch indicates that stale layers that were not launch layers in the previous build are kept.
+	KeepNonLaunch bool
+}
+
+// Build delegates to the Builder.
+func (s StaleLayerCleanup) Build(context libcnb.BuildContext) (libcnb.BuildResult, error) {
+	return s.Builder.Build(context)
 }
 
 type buildDelegate struct {
 	delegate libcnb.Builder
+	logger   bard.Logger
+
+	// candidates are the existing layer directories that are removed if they are not touched, keyed by path.  The
+	// value indicates whether the layer should be kept because it was not a launch layer.
+	candidates map[string]bool
 }
 
-func (b buildDelegate) Build(context libcnb.BuildContext) (libcnb.BuildResult, error) {
-	result, err := b.delegate.Build(context)
+func (b *buildDelegate) Build(context libcnb.BuildContext) (libcnb.BuildResult, error) {
+	var err error
+	if c, ok := b.delegate.(StaleLayerCleanup); ok {
+		b.candidates, err = b.existingLayers(context.Layers, c.KeepNonLaunch)
+	}
+
+	var result libcnb.BuildResult
+	if err == nil {
+		result, err = b.delegate.Build(context)
+	}
+
 	if err != nil {
+		b.candidates = nil
 		err = bard.IdentifiableError{
 			Name:        context.Buildpack.Info.Name,
 			Description: context.Buildpack.Info.Version,
@@ -49,3 +94,65 @@ func (b buildDelegate) Build(context libcnb.BuildContext) (libcnb.BuildResult, e
 
 	return result, err
 }
+
+// existingLayers lists the existing layer directories.  This must be done before libcnb removes the metadata of the
+// previous build.
+func (buildDelegate) existingLayers(layers libcnb.Layers, keepNonLaunch bool) (map[string]bool, error) {
+	file := filepath.Join(layers.Path, "*")
+	existing, err := filepath.Glob(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list files in %s: %w", file, err)
+	}
+
+	candidates := map[string]bool{}
+	for _, e := range existing {
+		name := filepath.Base(e)
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if info, err := os.Stat(e); err != nil {
+			return nil, fmt.Errorf("unable to stat %s: %w", e, err)
+		} else if !info.IsDir() {
+			continue
+		}
+
+		layer, err := layers.Layer(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read layer %s: %w", name, err)
+		}
+
+		candidates[e] = keepNonLaunch && !layer.Launch
+	}
+
+	return candidates, nil
+}
+
+// removeStaleLayers removes each candidate layer directory that was not touched by a LayerContributor.
+func (b buildDelegate) removeStaleLayers() {
+	var paths []string
+	for p := range b.candidates {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		name := filepath.Base(p)
+
+		if touchedLayers.touched(p) {
+			continue
+		}
+
+		if b.candidates[p] {
+			b.logger.Debug("Keeping stale non-launch layer %s", name)
+			continue
+		}
+
+		b.logger.Header("Removing stale layer %s", name)
+
+		if err := os.RemoveAll(p); err != nil {
+			b.logger.Header("%s Unable to remove stale layer %s: %s",
+				color.New(color.FgYellow, color.Bold).Sprint("Warning:"), name, err)
+		}
+	}
+}
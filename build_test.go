@@ -121,4 +121,93 @@ version = "test-version"`),
 			Err:         fmt.Errorf("test-error"),
 		}))
 	})
+
+	context("stale layers", func() {
+		var (
+			layer *mocks.LayerContributor
+		)
+
+		it.Before(func() {
+			Expect(ioutil.WriteFile(filepath.Join(buildpackPath, "buildpack.toml"), []byte(`[buildpack]
+name    = "test-name"
+version = "test-version"`),
+				0644)).To(Succeed())
+
+			for name, content := range map[string]string{
+				"test-current":      "launch = true",
+				"test-stale-launch": "launch = true",
+				"test-stale-build":  "build = true\ncache = true",
+				".test-hidden":      "",
+			} {
+				Expect(os.MkdirAll(filepath.Join(layersPath, name), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(layersPath, fmt.Sprintf("%s.toml", name)), []byte(content), 0644)).
+					To(Succeed())
+			}
+			Expect(ioutil.WriteFile(filepath.Join(layersPath, "store.toml"), []byte{}, 0644)).To(Succeed())
+
+			layer = &mocks.LayerContributor{}
+			layer.On("Name").Return("test-current")
+			layer.On("Contribute", mock.Anything).
+				Run(func(args mock.Arguments) {
+					lc := libpak.NewLayerContributor("test-current", map[string]interface{}{})
+					lc.Logger = bard.NewLogger(ioutil.Discard)
+
+					_, err := lc.Contribute(args.Get(0).(libcnb.Layer), func() (libcnb.Layer, error) {
+						return args.Get(0).(libcnb.Layer), nil
+					})
+					Expect(err).NotTo(HaveOccurred())
+				}).
+				Return(libcnb.Layer{Name: "test-current", Path: filepath.Join(layersPath, "test-current")}, nil)
+			builder.On("Build", mock.Anything).Return(libcnb.BuildResult{Layers: []libcnb.LayerContributor{layer}}, nil)
+		})
+
+		build := func(builder libcnb.Builder) {
+			libpak.Build(builder,
+				libcnb.WithArguments([]string{commandPath, layersPath, platformPath, buildpackPlanPath}),
+				libcnb.WithEnvironmentWriter(environmentWriter),
+				libcnb.WithExitHandler(exitHandler),
+				libcnb.WithTOMLWriter(tomlWriter),
+			)
+
+			Expect(exitHandler.Calls).To(BeEmpty())
+		}
+
+		it("does not remove stale layers by default", func() {
+			build(builder)
+
+			Expect(filepath.Join(layersPath, "test-current")).To(BeADirectory())
+			Expect(filepath.Join(layersPath, "test-stale-launch")).To(BeADirectory())
+			Expect(filepath.Join(layersPath, "test-stale-build")).To(BeADirectory())
+		})
+
+		it("removes layers not touched by a LayerContributor", func() {
+			build(libpak.StaleLayerCleanup{Builder: builder})
+
+			Expect(filepath.Join(layersPath, "test-current")).To(BeADirectory())
+			Expect(filepath.Join(layersPath, "test-stale-launch")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(layersPath, "test-stale-build")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(layersPath, ".test-hidden")).To(BeADirectory())
+			Expect(filepath.Join(layersPath, "store.toml")).To(BeARegularFile())
+		})
+
+		it("keeps stale non-launch layers", func() {
+			build(libpak.StaleLayerCleanup{Builder: builder, KeepNonLaunch: true})
+
+			Expect(filepath.Join(layersPath, "test-current")).To(BeADirectory())
+			Expect(filepath.Join(layersPath, "test-stale-launch")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(layersPath, "test-stale-build")).To(BeADirectory())
+		})
+
+		it("does not remove layers when builder fails", func() {
+			builder = &mocks.Builder{}
+			builder.On("Build", mock.Anything).Return(libcnb.BuildResult{}, fmt.Errorf("test-error"))
+
+			libpak.Build(libpak.StaleLayerCleanup{Builder: builder},
+				libcnb.WithArguments([]string{commandPath, layersPath, platformPath, buildpackPlanPath}),
+				libcnb.WithExitHandler(exitHandler),
+			)
+
+			Expect(filepath.Join(layersPath, "test-stale-launch")).To(BeADirectory())
+		})
+	})
 }
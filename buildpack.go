@@ -18,10 +18,13 @@ package libpak
 
 import (
 	"fmt"
+	"os"
+	"runtime"
 	"sort"
+	"strings"
 
-	"github.com/Masterminds/semver/v3"
 	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak/bard"
 )
 
 // License represents a license that a BuildpackDependency is distributed under.  At least one of Name or URI MUST be
@@ -57,22 +60,63 @@ type BuildpackDependency struct {
 
 	// Licenses are the stacks the dependency is distributed under.
 	Licenses []BuildpackDependencyLicense `mapstructure:"licenses" toml:"licenses"`
+
+	// Arch is the CPU architecture the dependency is compatible with, using GOARCH values.  If not set, the dependency
+	// is compatible with all architectures.
+	Arch string `mapstructure:"arch" toml:"arch,omitempty"`
+
+	// OS is the operating system the dependency is compatible with, using GOOS values.  If not set, the dependency is
+	// compatible with all operating systems.
+	OS string `mapstructure:"os" toml:"os,omitempty"`
+
+	// Channels are the named release channels (e.g. lts, latest, ea) that the dependency belongs to.  A channel name
+	// can be used in place of a version constraint during resolution.
+	Channels []string `mapstructure:"channels" toml:"channels,omitempty"`
+
+	// Tags are free-form labels, such as a variant (e.g. jdk or jre) or a distribution, that distinguish
+	// dependencies with the same ID and version.
+	Tags []string `mapstructure:"tags" toml:"tags,omitempty"`
+
+	// PURL is the package URL (https://github.com/package-url/purl-spec) that identifies the dependency.
+	PURL string `mapstructure:"purl" toml:"purl,omitempty"`
+
+	// CPEs are the Common Platform Enumeration names that identify the dependency.
+	CPEs []string `mapstructure:"cpes" toml:"cpes,omitempty"`
+
+	// Overridden indicates that the dependency was replaced or added by a user-supplied DependencyOverride.
+	Overridden bool `mapstructure:"overridden" toml:"overridden,omitempty"`
+}
+
+// CacheKey returns the key used to store the dependency in a DependencyCache.  This is the SHA256 of the dependency,
+// qualified by its OS and Arch if they are set, so that artifacts for different platforms are kept apart.
+func (b BuildpackDependency) CacheKey() string {
+	s := []string{b.SHA256}
+
+	if b.OS != "" {
+		s = append(s, b.OS)
+	}
+
+	if b.Arch != "" {
+		s = append(s, b.Arch)
+	}
+
+	return strings.Join(s, "-")
 }
 
 // BuildpackMetadata is an extension to libcnb.Buildpack's metadata with opinions.
 type BuildpackMetadata struct {
 
 	// DefaultVersions represent the default versions for dependencies keyed by Dependency.Id.
-	DefaultVersions map[string]string
+	DefaultVersions map[string]string `mapstructure:"default-versions" toml:"default-versions,omitempty"`
 
 	// Dependencies are the dependencies known to the buildpack.
-	Dependencies []BuildpackDependency
+	Dependencies []BuildpackDependency `mapstructure:"dependencies" toml:"dependencies,omitempty"`
 
 	// IncludeFiles describes the files to include in the package.
-	IncludeFiles []string
+	IncludeFiles []string `mapstructure:"include-files" toml:"include-files,omitempty"`
 
 	// PrePackage describes a command to invoke before packaging.
-	PrePackage string
+	PrePackage string `mapstructure:"pre-package" toml:"pre-package,omitempty"`
 }
 
 // NewBuildpackMetadata creates a new instance of BuildpackMetadata from the contents of libcnb.Buildpack.Metadata
@@ -133,6 +177,36 @@ func NewBuildpackMetadata(metadata map[string]interface{}) (BuildpackMetadata, e
 				}
 			}
 
+			if v, ok := v["channels"].([]interface{}); ok {
+				for _, v := range v {
+					d.Channels = append(d.Channels, v.(string))
+				}
+			}
+
+			if v, ok := v["tags"].([]interface{}); ok {
+				for _, v := range v {
+					d.Tags = append(d.Tags, v.(string))
+				}
+			}
+
+			if v, ok := v["arch"].(string); ok {
+				d.Arch = v
+			}
+
+			if v, ok := v["os"].(string); ok {
+				d.OS = v
+			}
+
+			if v, ok := v["purl"].(string); ok {
+				d.PURL = v
+			}
+
+			if v, ok := v["cpes"].([]interface{}); ok {
+				for _, v := range v {
+					d.CPEs = append(d.CPEs, v.(string))
+				}
+			}
+
 			m.Dependencies = append(m.Dependencies, d)
 		}
 	}
@@ -153,83 +227,542 @@ func NewBuildpackMetadata(metadata map[string]interface{}) (BuildpackMetadata, e
 // DependencyResolver provides functionality for resolving a dependency fiven a collection of constraints.
 type DependencyResolver struct {
 
+	// DefaultVersions are the default versions for dependencies keyed by dependency ID.
+	DefaultVersions map[string]string
+
 	// Dependencies are the dependencies to resolve against.
 	Dependencies []BuildpackDependency
 
+	// Logger is the logger used to write to the console.
+	Logger bard.Logger
+
 	// StackID is the stack id of the build.
 	StackID string
+
+	// Arch is the CPU architecture, using GOARCH values, to resolve dependencies for.  Defaults to runtime.GOARCH if
+	// not set.
+	Arch string
+
+	// OS is the operating system, using GOOS values, to resolve dependencies for.  Defaults to runtime.GOOS if not set.
+	OS string
+
+	// PrereleasePolicy is the policy for resolving prerelease versions.  Defaults to PrereleaseWhenRequested.
+	PrereleasePolicy PrereleasePolicy
+
+	// VersionScheme is the scheme used to parse and compare versions.  Defaults to SemverVersionScheme if not set.
+	VersionScheme VersionScheme
+
+	// VersionSchemes are schemes, keyed by dependency ID, that take precedence over VersionScheme for dependencies
+	// with that ID.
+	VersionSchemes map[string]VersionScheme
 }
 
-// NewDependencyResolver creates a new instance from the buildpack metadata and stack id.
+// NewDependencyResolver creates a new instance from the buildpack metadata and stack id, targeting the runtime
+// platform.  Any user-supplied dependency overrides are applied to the buildpack's dependencies.
 func NewDependencyResolver(context libcnb.BuildContext) (DependencyResolver, error) {
 	md, err := NewBuildpackMetadata(context.Buildpack.Metadata)
 	if err != nil {
 		return DependencyResolver{}, fmt.Errorf("unable to unmarshal buildpack metadata: %w", err)
 	}
 
-	return DependencyResolver{Dependencies: md.Dependencies, StackID: context.StackID}, nil
+	d := DependencyResolver{
+		DefaultVersions: md.DefaultVersions,
+		Dependencies:    md.Dependencies,
+		Logger:          bard.NewLogger(os.Stdout),
+		StackID:         context.StackID,
+		Arch:            runtime.GOARCH,
+		OS:              runtime.GOOS,
+	}
+
+	bindings, err := NewBindingResolver(context.Platform)
+	if err != nil {
+		return DependencyResolver{}, fmt.Errorf("unable to read bindings: %w", err)
+	}
+
+	overrides, err := NewDependencyOverrides(bindings.Bindings)
+	if err != nil {
+		return DependencyResolver{}, fmt.Errorf("unable to read dependency overrides: %w", err)
+	}
+
+	if err := d.ApplyOverrides(overrides); err != nil {
+		return DependencyResolver{}, fmt.Errorf("unable to apply dependency overrides: %w", err)
+	}
+
+	return d, nil
+}
+
+// PrereleasePolicy is the policy for resolving prerelease versions of dependencies.
+type PrereleasePolicy uint8
+
+const (
+	// PrereleaseWhenRequested resolves prerelease versions only when the version constraint names a prerelease or
+	// when a channel is requested.
+	PrereleaseWhenRequested PrereleasePolicy = iota
+
+	// PrereleaseExclude never resolves prerelease versions.
+	PrereleaseExclude
+
+	// PrereleaseInclude resolves prerelease versions whenever their release version satisfies the version constraint.
+	PrereleaseInclude
+)
+
+// VersionSource is the source of a version constraint used during resolution.
+type VersionSource string
+
+const (
+	// VersionSourceDefault indicates that the constraint came from the buildpack's default versions.
+	VersionSourceDefault VersionSource = "default-versions"
+
+	// VersionSourceEnvironment indicates that the constraint came from an environment variable.
+	VersionSourceEnvironment VersionSource = "environment"
+
+	// VersionSourceNone indicates that no constraint was found and all versions were considered.
+	VersionSourceNone VersionSource = "none"
+
+	// VersionSourcePlan indicates that the constraint came from a Buildpack Plan entry.
+	VersionSourcePlan VersionSource = "buildpack plan"
+)
+
+// ResolveWithDefaults resolves a dependency with a version constraint found through four methods, in order of
+// precedence:
+//
+// 1. An environment variable ($<key>)
+// 2. Buildpack plan entry
+// 3. Buildpack default version
+// 4. Empty version ("")
+//
+// The source of the constraint is logged and returned along with the resolved dependency.  Tags are passed to Resolve.
+func (d *DependencyResolver) ResolveWithDefaults(id string, key string, entry libcnb.BuildpackPlanEntry, tags ...string) (BuildpackDependency, VersionSource, error) {
+	version, source := d.version(id, key, entry)
+
+	switch source {
+	case VersionSourceEnvironment:
+		d.Logger.Body("Using version %s from $%s", version, key)
+	case VersionSourcePlan:
+		d.Logger.Body("Using version %s from buildpack plan", version)
+	case VersionSourceDefault:
+		if key != "" {
+			d.Logger.Body(bard.FormatUserConfig(key, "the version", version))
+		} else {
+			d.Logger.Body("Using default version %s", version)
+		}
+	}
+
+	dep, err := d.Resolve(id, version, tags...)
+	if err != nil {
+		return BuildpackDependency{}, source, err
+	}
+
+	return dep, source, nil
+}
+
+func (d DependencyResolver) version(id string, key string, entry libcnb.BuildpackPlanEntry) (string, VersionSource) {
+	if key != "" {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, VersionSourceEnvironment
+		}
+	}
+
+	if entry.Version != "" {
+		return entry.Version, VersionSourcePlan
+	}
+
+	if v, ok := d.DefaultVersions[id]; ok {
+		return v, VersionSourceDefault
+	}
+
+	return "", VersionSourceNone
+}
+
+// RejectionConstraint is the kind of constraint that a dependency failed during resolution.
+type RejectionConstraint string
+
+const (
+	// RejectedChannel indicates that a dependency does not belong to the requested channel.
+	RejectedChannel RejectionConstraint = "channel"
+
+	// RejectedPrerelease indicates that a dependency is a prerelease and the PrereleasePolicy does not allow it.
+	RejectedPrerelease RejectionConstraint = "prerelease"
+
+	// RejectedArch indicates that a dependency is not compatible with the target CPU architecture.
+	RejectedArch RejectionConstraint = "arch"
+
+	// RejectedOS indicates that a dependency is not compatible with the target operating system.
+	RejectedOS RejectionConstraint = "os"
+
+	// RejectedStack indicates that a dependency is not compatible with the stack of the build.
+	RejectedStack RejectionConstraint = "stack"
+
+	// RejectedTags indicates that a dependency does not have all of the requested tags.
+	RejectedTags RejectionConstraint = "tags"
+
+	// RejectedVersion indicates that a dependency does not satisfy the version constraint.
+	RejectedVersion RejectionConstraint = "version"
+)
+
+// RejectionReason describes a single constraint that a dependency failed during resolution.
+type RejectionReason struct {
+
+	// Constraint is the kind of constraint that was failed.
+	Constraint RejectionConstraint
+
+	// Message is a user readable description of the failure.
+	Message string
+}
+
+// DependencyRejection describes a dependency with a matching ID that was rejected during resolution.
+type DependencyRejection struct {
+
+	// Dependency is the rejected dependency.
+	Dependency BuildpackDependency
+
+	// Reasons are the constraints that the dependency failed.
+	Reasons []RejectionReason
 }
 
 // NoValidDependenciesError is returned when the resolver cannot find any valid dependencies given the constraints.
 type NoValidDependenciesError struct {
+
 	// Message is the error message
 	Message string
+
+	// ID is the dependency ID that was requested.
+	ID string
+
+	// Tags are the dependency tags that were requested.
+	Tags []string
+
+	// IDs are all the dependency IDs known to the resolver.  It is only populated when no dependency has a
+	// matching ID.
+	IDs []string
+
+	// Rejections are the dependencies with a matching ID, and the constraints that each failed.
+	Rejections []DependencyRejection
 }
 
 func (n NoValidDependenciesError) Error() string {
-	return n.Message
+	s := []string{n.Message}
+
+	if len(n.Rejections) == 0 && n.ID != "" {
+		s = append(s, fmt.Sprintf("  no dependencies with id %s in %s", n.ID, n.IDs))
+	}
+
+	for _, r := range n.Rejections {
+		var reasons []string
+		for _, reason := range r.Reasons {
+			reasons = append(reasons, reason.Message)
+		}
+
+		s = append(s, fmt.Sprintf("  %s", bard.FormatRejection(r.Dependency.ID, r.Dependency.Version, reasons)))
+	}
+
+	return strings.Join(s, "\n")
 }
 
 // Resolve returns the latest version of a dependency within the collection of Dependencies.  The candidate set is first
-// filtered by the constraints, then the remaining candidates are sorted for the latest result by semver semantics.
-// Version can contain wildcards and defaults to "*" if not specified.
-func (d *DependencyResolver) Resolve(id string, version string) (BuildpackDependency, error) {
+// filtered by the constraints, then the remaining candidates are sorted for the latest result by the semantics of the
+// dependency's VersionScheme.  Version can contain wildcards and defaults to "*" if not specified.  Version can also be
+// the name of a channel declared by dependencies with the ID.  If tags are specified, only dependencies that have all
+// of the tags are candidates.  If no candidates remain, a NoValidDependenciesError describing why each candidate was
+// rejected is returned.
+func (d *DependencyResolver) Resolve(id string, version string, tags ...string) (BuildpackDependency, error) {
+	candidates, err := d.candidates(id, version, tags)
+	if err != nil {
+		return BuildpackDependency{}, err
+	}
+
+	return candidates[0].dependency, nil
+}
+
+// ResolveAll returns every version of a dependency within the collection of Dependencies that satisfies the
+// constraints, sorted from latest to earliest by the semantics of the dependency's VersionScheme.  Constraints are
+// interpreted as they are by Resolve.  If no candidates remain, a NoValidDependenciesError describing why each
+// candidate was rejected is returned.
+func (d *DependencyResolver) ResolveAll(id string, version string, tags ...string) ([]BuildpackDependency, error) {
+	candidates, err := d.candidates(id, version, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var deps []BuildpackDependency
+	for _, c := range candidates {
+		deps = append(deps, c.dependency)
+	}
+
+	return deps, nil
+}
+
+// VersionLine is the granularity at which dependencies are grouped by GroupByLine.
+type VersionLine uint8
+
+const (
+	// MajorVersionLine groups dependencies by major version (e.g. 11).
+	MajorVersionLine VersionLine = iota
+
+	// MinorVersionLine groups dependencies by major and minor version (e.g. 11.0).
+	MinorVersionLine
+)
+
+// DependencyGroup is a collection of dependencies on the same version line.
+type DependencyGroup struct {
+
+	// Line is the version line of the group (e.g. 11 or 11.0).
+	Line string
+
+	// Dependencies are the dependencies on the version line, in the order they were grouped.
+	Dependencies []BuildpackDependency
+}
+
+// GroupByLine groups dependencies by their major or minor version line.  Groups are returned in the order that their
+// first dependency appears, and dependencies retain their order within a group, so grouping the result of ResolveAll
+// yields the latest version of each line as the first dependency of each group.
+func (d *DependencyResolver) GroupByLine(dependencies []BuildpackDependency, line VersionLine) ([]DependencyGroup, error) {
+	var groups []DependencyGroup
+	index := map[string]int{}
+
+	for _, c := range dependencies {
+		v, err := d.scheme(c.ID).ParseVersion(c.Version)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse version %s: %w", c.Version, err)
+		}
+
+		l := fmt.Sprintf("%d", v.Major())
+		if line == MinorVersionLine {
+			l = fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+		}
+
+		i, ok := index[l]
+		if !ok {
+			i = len(groups)
+			index[l] = i
+			groups = append(groups, DependencyGroup{Line: l})
+		}
+
+		groups[i].Dependencies = append(groups[i].Dependencies, c)
+	}
+
+	return groups, nil
+}
+
+type candidate struct {
+	dependency BuildpackDependency
+	version    Version
+}
+
+func (d *DependencyResolver) candidates(id string, version string, tags []string) ([]candidate, error) {
 	if version == "" {
 		version = "*"
 	}
 
-	vc, err := semver.NewConstraint(version)
-	if err != nil {
-		return BuildpackDependency{}, fmt.Errorf("invalid constraint %s: %w", vc, err)
+	scheme := d.scheme(id)
+
+	var (
+		channel string
+		vc      VersionConstraint
+	)
+
+	if d.isChannel(id, version) {
+		channel = version
+	} else {
+		var err error
+		if vc, err = scheme.ParseConstraint(version); err != nil {
+			return nil, fmt.Errorf("invalid constraint %s: %w", version, err)
+		}
 	}
 
-	var candidates []BuildpackDependency
+	var (
+		candidates []candidate
+		rejections []DependencyRejection
+	)
 	for _, c := range d.Dependencies {
-		v, err := semver.NewVersion(c.Version)
+		if c.ID != id {
+			continue
+		}
+
+		v, err := scheme.ParseVersion(c.Version)
 		if err != nil {
-			return BuildpackDependency{}, fmt.Errorf("unable to parse version %s: %w", c.Version, err)
+			return nil, fmt.Errorf("unable to parse version %s: %w", c.Version, err)
 		}
 
-		if c.ID == id && vc.Check(v) && d.contains(c.Stacks, d.StackID) {
-			candidates = append(candidates, c)
+		if reasons := d.reject(c, v, vc, channel, tags); len(reasons) > 0 {
+			rejections = append(rejections, DependencyRejection{Dependency: c, Reasons: reasons})
+			continue
 		}
+
+		candidates = append(candidates, candidate{dependency: c, version: v})
 	}
 
 	if len(candidates) == 0 {
-		return BuildpackDependency{}, NoValidDependenciesError{
-			Message: fmt.Sprintf("no valid dependencies for %s, %s, and %s in %s",
-				id, version, d.StackID, DependenciesFormatter(d.Dependencies)),
+		n := NoValidDependenciesError{
+			Message:    fmt.Sprintf("no valid dependencies for %s, %s, and %s", id, version, d.StackID),
+			ID:         id,
+			Tags:       tags,
+			Rejections: rejections,
 		}
-	}
 
-	sort.Slice(candidates, func(i int, j int) bool {
-		a, _ := semver.NewVersion(candidates[i].Version)
-		b, _ := semver.NewVersion(candidates[j].Version)
+		if len(tags) > 0 {
+			n.Message = fmt.Sprintf("no valid dependencies for %s, %s, %s, and %s", id, version, tags, d.StackID)
+		}
+
+		if len(rejections) == 0 {
+			n.IDs = d.ids()
+		}
 
-		return a.GreaterThan(b)
+		return nil, n
+	}
+
+	sort.SliceStable(candidates, func(i int, j int) bool {
+		return candidates[i].version.Compare(candidates[j].version) > 0
 	})
 
-	return candidates[0], nil
+	return candidates, nil
 }
 
 // Any indicates whether the collection of dependencies has any dependency that satisfies the constraints.  This is
 // used primarily to determine whether an optional dependency exists, before calling Resolve() which would throw an
 // error if one did not.
-func (d *DependencyResolver) Any(id string, version string) bool {
-	_, err := d.Resolve(id, version)
+func (d *DependencyResolver) Any(id string, version string, tags ...string) bool {
+	_, err := d.Resolve(id, version, tags...)
 	return err == nil
 }
 
+func (d DependencyResolver) ids() []string {
+	m := map[string]bool{}
+	for _, c := range d.Dependencies {
+		m[c.ID] = true
+	}
+
+	var ids []string
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func (d DependencyResolver) isChannel(id string, version string) bool {
+	for _, c := range d.Dependencies {
+		if c.ID == id && d.contains(c.Channels, version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d DependencyResolver) reject(dependency BuildpackDependency, version Version, constraint VersionConstraint, channel string, tags []string) []RejectionReason {
+	var r []RejectionReason
+
+	if channel != "" {
+		if !d.contains(dependency.Channels, channel) {
+			r = append(r, RejectionReason{
+				Constraint: RejectedChannel,
+				Message:    fmt.Sprintf("channel %s is not one of %s", channel, dependency.Channels),
+			})
+		} else if version.Prerelease() != "" && d.PrereleasePolicy == PrereleaseExclude {
+			r = append(r, RejectionReason{
+				Constraint: RejectedPrerelease,
+				Message:    fmt.Sprintf("version %s is a prerelease", version),
+			})
+		}
+	} else if reason, ok := d.rejectVersion(version, constraint); ok {
+		r = append(r, reason)
+	}
+
+	if !d.contains(dependency.Stacks, d.StackID) {
+		r = append(r, RejectionReason{
+			Constraint: RejectedStack,
+			Message:    fmt.Sprintf("stack %s is not one of %s", d.StackID, dependency.Stacks),
+		})
+	}
+
+	for _, t := range tags {
+		if !d.contains(dependency.Tags, t) {
+			r = append(r, RejectionReason{
+				Constraint: RejectedTags,
+				Message:    fmt.Sprintf("tags %s are not all in %s", tags, dependency.Tags),
+			})
+			break
+		}
+	}
+
+	if a := d.targetArch(); dependency.Arch != "" && dependency.Arch != a {
+		r = append(r, RejectionReason{
+			Constraint: RejectedArch,
+			Message:    fmt.Sprintf("arch %s is not %s", a, dependency.Arch),
+		})
+	}
+
+	if o := d.targetOS(); dependency.OS != "" && dependency.OS != o {
+		r = append(r, RejectionReason{
+			Constraint: RejectedOS,
+			Message:    fmt.Sprintf("os %s is not %s", o, dependency.OS),
+		})
+	}
+
+	return r
+}
+
+func (d DependencyResolver) rejectVersion(version Version, constraint VersionConstraint) (RejectionReason, bool) {
+	prerelease := version.Prerelease() != ""
+
+	if prerelease && d.PrereleasePolicy == PrereleaseExclude {
+		return RejectionReason{
+			Constraint: RejectedPrerelease,
+			Message:    fmt.Sprintf("version %s is a prerelease", version),
+		}, true
+	}
+
+	if constraint.Check(version) {
+		return RejectionReason{}, false
+	}
+
+	if prerelease && constraint.Check(version.Release()) {
+		if d.PrereleasePolicy == PrereleaseInclude {
+			return RejectionReason{}, false
+		}
+
+		return RejectionReason{
+			Constraint: RejectedPrerelease,
+			Message:    fmt.Sprintf("version %s is a prerelease and %s does not request one", version, constraint),
+		}, true
+	}
+
+	return RejectionReason{
+		Constraint: RejectedVersion,
+		Message:    fmt.Sprintf("version %s does not satisfy %s", version, constraint),
+	}, true
+}
+
+func (d DependencyResolver) targetArch() string {
+	if d.Arch != "" {
+		return d.Arch
+	}
+
+	return runtime.GOARCH
+}
+
+func (d DependencyResolver) targetOS() string {
+	if d.OS != "" {
+		return d.OS
+	}
+
+	return runtime.GOOS
+}
+
+func (d DependencyResolver) scheme(id string) VersionScheme {
+	if s, ok := d.VersionSchemes[id]; ok {
+		return s
+	}
+
+	if d.VersionScheme != nil {
+		return d.VersionScheme
+	}
+
+	return SemverVersionScheme{}
+}
+
 func (DependencyResolver) contains(candidates []string, value string) bool {
 	for _, c := range candidates {
 		if c == value {
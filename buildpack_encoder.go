@@ -0,0 +1,650 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EncodeBuildpackMetadata encodes metadata as the [metadata] section of a buildpack.toml.
+func EncodeBuildpackMetadata(metadata BuildpackMetadata) ([]byte, error) {
+	b := &bytes.Buffer{}
+
+	e := toml.NewEncoder(b)
+	e.Indent = ""
+
+	if err := e.Encode(map[string]interface{}{"metadata": metadata}); err != nil {
+		return nil, fmt.Errorf("unable to encode metadata %+v: %w", metadata, err)
+	}
+
+	var lines []string
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if l == "" {
+			continue
+		}
+
+		if _, ok := parseTOMLHeader(l); ok && len(lines) > 0 {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, l)
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// ReplaceBuildpackMetadata replaces the keys of the [metadata] section of the contents of a buildpack.toml that are
+// described by BuildpackMetadata with the encoded metadata.  Replaced keys and tables keep their original position,
+// indentation, and the comments that precede them.  Values that are unchanged are not rewritten, and replaced values
+// keep their trailing comments and, for arrays, their multi-line layout.  Dependency tables are matched by their position.  Keys and tables
+// that do not already exist are added after the existing ones, and those that no longer exist are removed.  Content
+// outside of the [metadata] section and keys and tables within the [metadata] section that are not described by
+// BuildpackMetadata are preserved.
+func ReplaceBuildpackMetadata(content []byte, metadata BuildpackMetadata) ([]byte, error) {
+	encoded, err := EncodeBuildpackMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	original, trailing := parseTOMLSections(string(content))
+	replacement, _ := parseTOMLSections(string(encoded))
+
+	var (
+		root        tomlSection
+		units       [][]tomlSection
+		occurrences = map[string]int{}
+		used        = map[int]bool{}
+	)
+
+	for i := 0; i < len(replacement); {
+		if replacement[i].name == "metadata" {
+			root = replacement[i]
+		}
+
+		if !isReplacedTable(replacement[i].name) {
+			i++
+			continue
+		}
+
+		j := unitEnd(replacement, i)
+		units = append(units, replacement[i:j])
+		i = j
+	}
+
+	var (
+		out       []tomlSection
+		rootIndex = -1
+		first     = -1
+		last      = -1
+		byName    = map[string]int{}
+	)
+
+	for i := 0; i < len(original); {
+		s := original[i]
+
+		if !isReplacedTable(s.name) {
+			if s.name == "metadata" {
+				s.entries = mergeTOMLEntries(s.entries, root.entries, isReplacedKey)
+				rootIndex = len(out)
+			}
+
+			out = append(out, s)
+			i++
+			continue
+		}
+
+		j := unitEnd(original, i)
+
+		if first == -1 {
+			first = len(out)
+		}
+
+		n := occurrences[s.name]
+		occurrences[s.name]++
+
+		for k, u := range units {
+			if u[0].name != s.name {
+				continue
+			}
+
+			if n == 0 {
+				used[k] = true
+				out = append(out, mergeTOMLUnit(original[i:j], u)...)
+				break
+			}
+			n--
+		}
+
+		last = len(out)
+		byName[s.name] = len(out)
+		i = j
+	}
+
+	inserts := map[int][]tomlSection{}
+
+	if rootIndex == -1 && len(root.entries) > 0 {
+		i := len(out)
+		if first != -1 {
+			i = first
+		}
+
+		root.comments = []string{""}
+		inserts[i] = append(inserts[i], root)
+	}
+
+	// Tables are added after existing tables of the same name first, so that they stay together
+	for _, existing := range []bool{true, false} {
+		for k, u := range units {
+			i, ok := byName[u[0].name]
+			if used[k] || ok != existing {
+				continue
+			}
+
+			switch {
+			case ok:
+			case last != -1:
+				i = last
+			case rootIndex != -1:
+				i = rootIndex + 1
+			default:
+				i = len(out)
+			}
+
+			for _, s := range u {
+				s.comments = []string{""}
+				inserts[i] = append(inserts[i], s)
+			}
+		}
+	}
+
+	var lines []string
+	for i := 0; i <= len(out); i++ {
+		for _, s := range inserts[i] {
+			lines = append(lines, s.lines()...)
+		}
+
+		if i < len(out) {
+			if len(inserts[i]) > 0 && (len(out[i].comments) == 0 || strings.TrimSpace(out[i].comments[0]) != "") {
+				out[i].comments = append([]string{""}, out[i].comments...)
+			}
+
+			lines = append(lines, out[i].lines()...)
+		}
+	}
+	lines = append(lines, trailing...)
+
+	return []byte(strings.Join(trimLeadingBlank(trimTrailingBlank(lines)), "\n") + "\n"), nil
+}
+
+type tomlEntry struct {
+	comments []string
+	key      string
+	lines    []string
+}
+
+type tomlSection struct {
+	comments []string
+	header   string
+	name     string
+	entries  []tomlEntry
+}
+
+func (t tomlSection) lines() []string {
+	lines := append([]string{}, t.comments...)
+
+	if t.header != "" {
+		lines = append(lines, t.header)
+	}
+
+	for _, e := range t.entries {
+		lines = append(lines, e.comments...)
+		lines = append(lines, e.lines...)
+	}
+
+	return lines
+}
+
+// parseTOMLSections splits the contents of a TOML document into sections, each containing the entries of a table.  The
+// blank and comment lines preceding an entry or a table header are kept with it.  Those at the end of the document are
+// returned separately.
+func parseTOMLSections(content string) ([]tomlSection, []string) {
+	var (
+		sections = []tomlSection{{}}
+		pending  []string
+		scanner  tomlScanner
+	)
+
+	for _, l := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		current := &sections[len(sections)-1]
+
+		if scanner.open() {
+			e := &current.entries[len(current.entries)-1]
+			e.lines = append(e.lines, l)
+			scanner.scan(l)
+			continue
+		}
+
+		if name, ok := parseTOMLHeader(l); ok {
+			sections = append(sections, tomlSection{comments: pending, header: l, name: name})
+			pending = nil
+			continue
+		}
+
+		if t := strings.TrimSpace(l); t == "" || strings.HasPrefix(t, "#") {
+			pending = append(pending, l)
+			continue
+		}
+
+		e := tomlEntry{comments: pending, lines: []string{l}}
+		if key, _, ok := parseTOMLEntry(l); ok {
+			e.key = key
+		}
+		current.entries = append(current.entries, e)
+		pending = nil
+
+		scanner.scan(l)
+	}
+
+	return sections, pending
+}
+
+// mergeTOMLEntries replaces the value of each replaced entry in original with the value of the entry with the same
+// key in replacement, removes replaced entries that do not exist in replacement, and adds entries from replacement
+// that do not exist in original.
+func mergeTOMLEntries(original []tomlEntry, replacement []tomlEntry, replaced func(key string) bool) []tomlEntry {
+	values := map[string][]string{}
+	for _, e := range replacement {
+		values[e.key] = e.lines
+	}
+
+	var (
+		out    []tomlEntry
+		indent string
+		used   = map[string]bool{}
+	)
+
+	for _, e := range original {
+		indent = leadingWhitespace(e.lines[0])
+
+		if e.key == "" || !replaced(e.key) {
+			out = append(out, e)
+			continue
+		}
+
+		v, ok := values[e.key]
+		if !ok || used[e.key] {
+			continue
+		}
+
+		used[e.key] = true
+		e.lines = replaceTOMLValue(e.lines, v)
+		out = append(out, e)
+	}
+
+	for _, e := range replacement {
+		if !used[e.key] {
+			out = append(out, tomlEntry{key: e.key, lines: indentLines(indent, e.lines)})
+		}
+	}
+
+	return out
+}
+
+// mergeTOMLUnit merges a replaced table and its sub-tables with their replacement.  Sub-tables are matched by name
+// and position.
+func mergeTOMLUnit(original []tomlSection, replacement []tomlSection) []tomlSection {
+	s := original[0]
+	s.entries = mergeTOMLEntries(s.entries, replacement[0].entries, func(string) bool { return true })
+	out := []tomlSection{s}
+
+	var (
+		occurrences = map[string]int{}
+		used        = map[int]bool{}
+	)
+
+	for _, s := range original[1:] {
+		n := occurrences[s.name]
+		occurrences[s.name]++
+
+		for k, r := range replacement[1:] {
+			if r.name != s.name {
+				continue
+			}
+
+			if n == 0 {
+				used[k] = true
+				s.entries = mergeTOMLEntries(s.entries, r.entries, func(string) bool { return true })
+				out = append(out, s)
+				break
+			}
+			n--
+		}
+	}
+
+	for k, r := range replacement[1:] {
+		if !used[k] {
+			out = append(out, r)
+		}
+	}
+
+	return out
+}
+
+// unitEnd returns the index after the table at index and all of its sub-tables.
+func unitEnd(sections []tomlSection, index int) int {
+	j := index + 1
+	for j < len(sections) && strings.HasPrefix(sections[j].name, sections[index].name+".") {
+		j++
+	}
+
+	return j
+}
+
+func indentLines(indent string, lines []string) []string {
+	var out []string
+	for i, l := range lines {
+		if i == 0 {
+			l = indent + strings.TrimLeft(l, " \t")
+		}
+		out = append(out, l)
+	}
+
+	return out
+}
+
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// replaceTOMLValue replaces the value of an entry with the value of its replacement.  If the values are equal, the
+// original entry is returned unchanged.  Otherwise the original key, alignment, and trailing comment are kept, and an
+// array that spanned multiple lines is written with one element per line.
+func replaceTOMLValue(original []string, replacement []string) []string {
+	if equalTOMLValues(original, replacement) {
+		return original
+	}
+
+	_, start, ok := parseTOMLEntry(original[0])
+	_, rStart, rOk := parseTOMLEntry(replacement[0])
+	if !ok || !rOk || len(replacement) != 1 {
+		return indentLines(leadingWhitespace(original[0]), replacement)
+	}
+
+	prefix := original[0][:start]
+	value := strings.TrimSpace(replacement[0][rStart:])
+
+	var (
+		comment string
+		scanner tomlScanner
+	)
+	for _, l := range original {
+		if i := scanner.scan(l); i != -1 && l == original[len(original)-1] {
+			comment = l[len(strings.TrimRight(l[:i], " \t")):]
+		}
+	}
+
+	elements, isArray := splitTOMLArray(value)
+	if len(original) < 3 || !isArray || len(elements) == 0 {
+		return []string{prefix + value + comment}
+	}
+
+	var (
+		indent   = leadingWhitespace(original[1])
+		closing  = original[len(original)-1]
+		trailing = strings.HasSuffix(strings.TrimSpace(stripTOMLComment(original[len(original)-2])), ",")
+	)
+
+	lines := []string{prefix + "["}
+	for i, e := range elements {
+		if i < len(elements)-1 || trailing {
+			e += ","
+		}
+		lines = append(lines, indent+e)
+	}
+
+	return append(lines, leadingWhitespace(closing)+"]"+comment)
+}
+
+// equalTOMLValues returns whether two entries decode to the same value.
+func equalTOMLValues(a []string, b []string) bool {
+	var am, bm map[string]interface{}
+
+	if _, err := toml.Decode(strings.Join(a, "\n"), &am); err != nil {
+		return false
+	}
+	if _, err := toml.Decode(strings.Join(b, "\n"), &bm); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(am, bm)
+}
+
+// splitTOMLArray splits a single line TOML array into its elements.
+func splitTOMLArray(value string) ([]string, bool) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, false
+	}
+
+	var (
+		elements []string
+		scanner  tomlScanner
+		start    = 1
+	)
+
+	for i := 1; i < len(value)-1; i++ {
+		scanner.scan(value[i : i+1])
+		if value[i] == ',' && !scanner.open() {
+			elements = append(elements, strings.TrimSpace(value[start:i]))
+			start = i + 1
+		}
+	}
+
+	if last := strings.TrimSpace(value[start : len(value)-1]); last != "" {
+		elements = append(elements, last)
+	}
+
+	return elements, true
+}
+
+func stripTOMLComment(line string) string {
+	var scanner tomlScanner
+	if i := scanner.scan(line); i != -1 {
+		return line[:i]
+	}
+
+	return line
+}
+
+// tomlScanner tracks the strings, arrays, and inline tables of a TOML value that may span multiple lines.
+type tomlScanner struct {
+	depth     int
+	multiline string
+}
+
+func (t tomlScanner) open() bool {
+	return t.depth > 0 || t.multiline != ""
+}
+
+// scan scans a line of a TOML document and returns the index of its trailing comment, or -1 if it has none.
+func (t *tomlScanner) scan(line string) int {
+	for i := 0; i < len(line); i++ {
+		if t.multiline != "" {
+			switch {
+			case t.multiline == `"""` && line[i] == '\\':
+				i++
+			case strings.HasPrefix(line[i:], t.multiline):
+				// Up to two quotes immediately before the closing delimiter are part of the string
+				j := i
+				for j < len(line) && line[j] == t.multiline[0] {
+					j++
+				}
+				i = j - 1
+				t.multiline = ""
+			}
+			continue
+		}
+
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], `"""`) || strings.HasPrefix(line[i:], `'''`):
+			t.multiline = line[i : i+3]
+			i += 2
+		case c == '"' || c == '\'':
+			for i++; i < len(line) && line[i] != c; i++ {
+				if c == '"' && line[i] == '\\' {
+					i++
+				}
+			}
+		case c == '#':
+			return i
+		case c == '[' || c == '{':
+			t.depth++
+		case c == ']' || c == '}':
+			t.depth--
+		}
+	}
+
+	return -1
+}
+
+// parseTOMLEntry returns the key of a key/value line, with quotes removed and dotted parts joined by ., and the index
+// of its value.
+func parseTOMLEntry(line string) (string, int, bool) {
+	key, rest, ok := parseTOMLKey(line)
+	if !ok || !strings.HasPrefix(rest, "=") {
+		return "", 0, false
+	}
+
+	return key, len(line) - len(strings.TrimLeft(rest[1:], " \t")), true
+}
+
+// parseTOMLHeader returns the name of the table declared by a table or array of tables header line.
+func parseTOMLHeader(line string) (string, bool) {
+	t := strings.TrimSpace(line)
+
+	open, close := "[", "]"
+	if strings.HasPrefix(t, "[[") {
+		open, close = "[[", "]]"
+	}
+
+	if !strings.HasPrefix(t, open) {
+		return "", false
+	}
+
+	name, rest, ok := parseTOMLKey(t[len(open):])
+	if !ok || !strings.HasPrefix(rest, close) {
+		return "", false
+	}
+
+	if rest = strings.TrimSpace(rest[len(close):]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", false
+	}
+
+	return name, true
+}
+
+// parseTOMLKey parses the bare, quoted, or dotted key at the start of s.  It returns the key, with quotes removed and
+// dotted parts joined by ., and the remainder of s after any whitespace.
+func parseTOMLKey(s string) (string, string, bool) {
+	var parts []string
+
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return "", "", false
+		}
+
+		switch s[0] {
+		case '"':
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(s) {
+				return "", "", false
+			}
+
+			p, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				p = s[1:i]
+			}
+			parts = append(parts, p)
+			s = s[i+1:]
+		case '\'':
+			i := strings.IndexByte(s[1:], '\'')
+			if i == -1 {
+				return "", "", false
+			}
+
+			parts = append(parts, s[1:i+1])
+			s = s[i+2:]
+		default:
+			i := 0
+			for i < len(s) && isBareKeyCharacter(s[i]) {
+				i++
+			}
+			if i == 0 {
+				return "", "", false
+			}
+
+			parts = append(parts, s[:i])
+			s = s[i:]
+		}
+
+		if s = strings.TrimLeft(s, " \t"); !strings.HasPrefix(s, ".") {
+			return strings.Join(parts, "."), s, true
+		}
+		s = s[1:]
+	}
+}
+
+func isBareKeyCharacter(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+func isReplacedKey(key string) bool {
+	key = strings.SplitN(key, ".", 2)[0]
+	return key == "default-versions" || key == "dependencies" || key == "include-files" || key == "pre-package"
+}
+
+func isReplacedTable(table string) bool {
+	return table == "metadata.default-versions" || table == "metadata.dependencies" ||
+		strings.HasPrefix(table, "metadata.default-versions.") || strings.HasPrefix(table, "metadata.dependencies.")
+}
+
+func trimLeadingBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+
+	return lines
+}
+
+func trimTrailingBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,387 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/sclevine/spec"
+)
+
+func testBuildpackEncoder(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		metadata libpak.BuildpackMetadata
+	)
+
+	it.Before(func() {
+		metadata = libpak.BuildpackMetadata{
+			DefaultVersions: map[string]string{"test-id": "1.*"},
+			Dependencies: []libpak.BuildpackDependency{
+				{
+					ID:      "test-id",
+					Name:    "test-name",
+					Version: "1.1.1",
+					URI:     "test-uri",
+					SHA256:  "test-sha256",
+					Stacks:  []string{"test-stack"},
+					Licenses: []libpak.BuildpackDependencyLicense{
+						{Type: "test-type", URI: "test-uri"},
+					},
+					Arch: "amd64",
+				},
+			},
+			IncludeFiles: []string{"buildpack.toml"},
+			PrePackage:   "test-pre-package",
+		}
+	})
+
+	it("encodes metadata", func() {
+		Expect(libpak.EncodeBuildpackMetadata(metadata)).To(Equal([]byte(`[metadata]
+include-files = ["buildpack.toml"]
+pre-package = "test-pre-package"
+
+[metadata.default-versions]
+test-id = "1.*"
+
+[[metadata.dependencies]]
+id = "test-id"
+name = "test-name"
+version = "1.1.1"
+uri = "test-uri"
+sha256 = "test-sha256"
+stacks = ["test-stack"]
+arch = "amd64"
+
+[[metadata.dependencies.licenses]]
+type = "test-type"
+uri = "test-uri"
+`)))
+	})
+
+	it("replaces metadata preserving other content", func() {
+		content := []byte(`# test-comment-1
+api = "0.2"
+
+[buildpack]
+id      = "test-id"
+version = "{{.Version}}"
+
+[metadata]
+# test-comment-2
+pre-package   = "old-pre-package"
+include-files = [
+  "old-include-file",
+  "buildpack.toml",
+]
+test-key      = "test-value"
+
+[metadata.default-versions]
+test-id = "0.*"
+
+[[metadata.configurations]]
+name    = "TEST_CONFIGURATION"
+default = "test-default"
+
+[[metadata.dependencies]]
+id      = "test-id"
+name    = "test-name"
+version = "0.1.1"
+uri     = "old-uri"
+sha256  = "old-sha256"
+stacks  = [ "test-stack" ]
+
+  [[metadata.dependencies.licenses]]
+  type = "test-type"
+  uri  = "test-uri"
+
+# test-comment-3
+[[stacks]]
+id = "test-stack"
+`)
+
+		Expect(libpak.ReplaceBuildpackMetadata(content, metadata)).To(Equal([]byte(`# test-comment-1
+api = "0.2"
+
+[buildpack]
+id      = "test-id"
+version = "{{.Version}}"
+
+[metadata]
+# test-comment-2
+pre-package   = "test-pre-package"
+include-files = [
+  "buildpack.toml",
+]
+test-key      = "test-value"
+
+[metadata.default-versions]
+test-id = "1.*"
+
+[[metadata.configurations]]
+name    = "TEST_CONFIGURATION"
+default = "test-default"
+
+[[metadata.dependencies]]
+id      = "test-id"
+name    = "test-name"
+version = "1.1.1"
+uri     = "test-uri"
+sha256  = "test-sha256"
+stacks  = [ "test-stack" ]
+arch = "amd64"
+
+  [[metadata.dependencies.licenses]]
+  type = "test-type"
+  uri  = "test-uri"
+
+# test-comment-3
+[[stacks]]
+id = "test-stack"
+`)))
+	})
+
+	it("replaces metadata preserving positions and comments", func() {
+		content := []byte(`[metadata]
+# test-comment-1
+include-files = ["old-include-file"]
+custom        = "test-custom"
+
+# test-comment-2
+pre-package = "old-pre-package"
+
+[[metadata.dependencies]]
+# test-comment-3
+id      = "test-id"
+name    = "test-name"
+# test-comment-4
+version = "0.1.1"
+uri     = "old-uri"
+sha256  = "old-sha256"
+stacks  = [ "test-stack" ]
+
+# test-comment-5
+[[metadata.dependencies.licenses]]
+type = "test-type"
+uri  = "test-uri"
+
+# test-comment-6
+[[metadata.dependencies]]
+id = "test-id-2"
+`)
+
+		Expect(libpak.ReplaceBuildpackMetadata(content, metadata)).To(Equal([]byte(`[metadata]
+# test-comment-1
+include-files = ["buildpack.toml"]
+custom        = "test-custom"
+
+# test-comment-2
+pre-package = "test-pre-package"
+
+[[metadata.dependencies]]
+# test-comment-3
+id      = "test-id"
+name    = "test-name"
+# test-comment-4
+version = "1.1.1"
+uri     = "test-uri"
+sha256  = "test-sha256"
+stacks  = [ "test-stack" ]
+arch = "amd64"
+
+# test-comment-5
+[[metadata.dependencies.licenses]]
+type = "test-type"
+uri  = "test-uri"
+
+[metadata.default-versions]
+test-id = "1.*"
+`)))
+	})
+
+	it("adds new dependencies after existing dependencies", func() {
+		metadata.Dependencies = append(metadata.Dependencies, libpak.BuildpackDependency{
+			ID:      "test-id-2",
+			Name:    "test-name-2",
+			Version: "2.2.2",
+		})
+
+		content := []byte(`[[metadata.dependencies]]
+# test-comment-1
+id = "test-id"
+
+[[stacks]]
+id = "test-stack"
+`)
+
+		Expect(libpak.ReplaceBuildpackMetadata(content, metadata)).To(Equal([]byte(`[metadata]
+include-files = ["buildpack.toml"]
+pre-package = "test-pre-package"
+
+[[metadata.dependencies]]
+# test-comment-1
+id = "test-id"
+name = "test-name"
+version = "1.1.1"
+uri = "test-uri"
+sha256 = "test-sha256"
+stacks = ["test-stack"]
+arch = "amd64"
+
+[[metadata.dependencies.licenses]]
+type = "test-type"
+uri = "test-uri"
+
+[[metadata.dependencies]]
+id = "test-id-2"
+name = "test-name-2"
+version = "2.2.2"
+uri = ""
+sha256 = ""
+
+[metadata.default-versions]
+test-id = "1.*"
+
+[[stacks]]
+id = "test-stack"
+`)))
+	})
+
+	it("keeps trailing comments of replaced values", func() {
+		content := []byte(`[metadata]
+pre-package = "old-pre-package" # test-comment-1
+include-files = [
+  "old-include-file", # test-comment-2
+] # test-comment-3
+`)
+
+		Expect(libpak.ReplaceBuildpackMetadata(content, libpak.BuildpackMetadata{
+			IncludeFiles: []string{"buildpack.toml", "bin/build"},
+			PrePackage:   "test-pre-package",
+		})).To(Equal([]byte(`[metadata]
+pre-package = "test-pre-package" # test-comment-1
+include-files = [
+  "buildpack.toml",
+  "bin/build",
+] # test-comment-3
+`)))
+	})
+
+	it("does not rewrite unchanged values", func() {
+		content := []byte(`[metadata]
+include-files = [ "buildpack.toml" ] # test-comment
+pre-package   = 'test-pre-package'
+`)
+
+		Expect(libpak.ReplaceBuildpackMetadata(content, libpak.BuildpackMetadata{
+			IncludeFiles: []string{"buildpack.toml"},
+			PrePackage:   "test-pre-package",
+		})).To(Equal(content))
+	})
+
+	it("preserves strings containing escapes and brackets", func() {
+		content := []byte(`[metadata]
+test-escaped   = "test-\"[value" # test-comment
+test-multiline = """
+[ test-value "
+[[metadata.dependencies]]
+"""
+test-literal   = '''
+]]'''
+pre-package    = "old-pre-package"
+`)
+
+		b, err := libpak.ReplaceBuildpackMetadata(content, libpak.BuildpackMetadata{PrePackage: "test-pre-package"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(b)).To(Equal(`[metadata]
+test-escaped   = "test-\"[value" # test-comment
+test-multiline = """
+[ test-value "
+[[metadata.dependencies]]
+"""
+test-literal   = '''
+]]'''
+pre-package    = "test-pre-package"
+`))
+	})
+
+	it("replaces dotted keys", func() {
+		content := []byte(`[metadata]
+default-versions.test-id = "0.*"
+"pre-package" = "old-pre-package"
+
+[metadata."dependencies"]
+`)
+
+		Expect(libpak.ReplaceBuildpackMetadata(content, libpak.BuildpackMetadata{
+			DefaultVersions: map[string]string{"test-id": "1.*"},
+			PrePackage:      "test-pre-package",
+		})).To(Equal([]byte(`[metadata]
+"pre-package" = "test-pre-package"
+
+[metadata.default-versions]
+test-id = "1.*"
+`)))
+	})
+
+	it("round trips metadata", func() {
+		content := []byte(`api = "0.2"
+
+[[metadata.dependencies]]
+id      = "test-id"
+name    = "test-name"
+version = "0.1.1"
+uri     = "old-uri"
+sha256  = "old-sha256"
+stacks  = [ "test-stack" ]
+
+[metadata]
+pre-package = "old-pre-package"
+
+[[stacks]]
+id = "test-stack"
+`)
+
+		b, err := libpak.ReplaceBuildpackMetadata(content, metadata)
+		Expect(err).NotTo(HaveOccurred())
+
+		var buildpack libcnb.Buildpack
+		_, err = toml.Decode(string(b), &buildpack)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(buildpack.API).To(Equal("0.2"))
+		Expect(buildpack.Stacks).To(HaveLen(1))
+		Expect(libpak.NewBuildpackMetadata(buildpack.Metadata)).To(Equal(metadata))
+	})
+
+	it("adds metadata if missing", func() {
+		b, err := libpak.ReplaceBuildpackMetadata([]byte("api = \"0.2\"\n"), libpak.BuildpackMetadata{PrePackage: "test-pre-package"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(b)).To(Equal(`api = "0.2"
+
+[metadata]
+pre-package = "test-pre-package"
+`))
+	})
+}
@@ -17,10 +17,17 @@
 package libpak_test
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/buildpacks/libcnb"
 	. "github.com/onsi/gomega"
 	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
 	"github.com/sclevine/spec"
 )
 
@@ -49,6 +56,12 @@ func testBuildpack(t *testing.T, context spec.G, it spec.S) {
 								"uri":  "test-uri",
 							},
 						},
+						"channels": []interface{}{"test-channel"},
+						"tags":     []interface{}{"test-tag"},
+						"purl":     "pkg:generic/test-id@1.1.1",
+						"cpes":     []interface{}{"test-cpe"},
+						"arch":     "test-arch",
+						"os":       "test-os",
 					},
 				},
 				"include-files": []interface{}{"test-include-file"},
@@ -73,6 +86,12 @@ func testBuildpack(t *testing.T, context spec.G, it spec.S) {
 								URI:  "test-uri",
 							},
 						},
+						Channels: []string{"test-channel"},
+						Tags:     []string{"test-tag"},
+						PURL:     "pkg:generic/test-id@1.1.1",
+						CPEs:     []string{"test-cpe"},
+						Arch:     "test-arch",
+						OS:       "test-os",
 					},
 				},
 				IncludeFiles: []string{"test-include-file"},
@@ -83,6 +102,18 @@ func testBuildpack(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("BuildpackDependency", func() {
+
+		it("uses SHA256 as cache key", func() {
+			Expect(libpak.BuildpackDependency{SHA256: "test-sha256"}.CacheKey()).To(Equal("test-sha256"))
+		})
+
+		it("qualifies cache key with platform", func() {
+			Expect(libpak.BuildpackDependency{SHA256: "test-sha256", OS: "linux", Arch: "arm64"}.CacheKey()).
+				To(Equal("test-sha256-linux-arm64"))
+		})
+	})
+
 	context("DependencyResolver", func() {
 		var (
 			resolver libpak.DependencyResolver
@@ -183,6 +214,224 @@ func testBuildpack(t *testing.T, context spec.G, it spec.S) {
 				}))
 			})
 
+			it("filters by arch and os", func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.1",
+						URI:     "test-uri-amd64",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+						Arch:    "amd64",
+						OS:      "linux",
+					},
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.1",
+						URI:     "test-uri-arm64",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+						Arch:    "arm64",
+						OS:      "linux",
+					},
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.1",
+						URI:     "test-uri-darwin",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+						Arch:    "arm64",
+						OS:      "darwin",
+					},
+				}
+				resolver.StackID = "test-stack-1"
+				resolver.Arch = "arm64"
+				resolver.OS = "linux"
+
+				Expect(resolver.Resolve("test-id", "1.1")).To(Equal(resolver.Dependencies[1]))
+			})
+
+			it("defaults to runtime platform", func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.1",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+						Arch:    "test-arch",
+					},
+				}
+				resolver.StackID = "test-stack-1"
+
+				_, err := resolver.Resolve("test-id", "1.1")
+				Expect(err).To(BeAssignableToTypeOf(libpak.NoValidDependenciesError{}))
+				Expect(err.(libpak.NoValidDependenciesError).Rejections[0].Reasons).To(Equal([]libpak.RejectionReason{
+					{
+						Constraint: libpak.RejectedArch,
+						Message:    fmt.Sprintf("arch %s is not test-arch", runtime.GOARCH),
+					},
+				}))
+			})
+
+			context("prereleases", func() {
+				it.Before(func() {
+					resolver.Dependencies = []libpak.BuildpackDependency{
+						{
+							ID:      "test-id",
+							Name:    "test-name",
+							Version: "1.1.0",
+							URI:     "test-uri",
+							SHA256:  "test-sha256",
+							Stacks:  []string{"test-stack-1"},
+						},
+						{
+							ID:      "test-id",
+							Name:    "test-name",
+							Version: "1.2.0-rc1",
+							URI:     "test-uri",
+							SHA256:  "test-sha256",
+							Stacks:  []string{"test-stack-1"},
+						},
+					}
+					resolver.StackID = "test-stack-1"
+				})
+
+				it("resolves prerelease only when requested by default", func() {
+					Expect(resolver.Resolve("test-id", "1.*")).To(Equal(resolver.Dependencies[0]))
+					Expect(resolver.Resolve("test-id", "1.2.0-rc1")).To(Equal(resolver.Dependencies[1]))
+
+					_, err := resolver.Resolve("test-id", "1.2.*")
+					Expect(err.(libpak.NoValidDependenciesError).Rejections[1].Reasons).To(Equal([]libpak.RejectionReason{
+						{
+							Constraint: libpak.RejectedPrerelease,
+							Message:    "version 1.2.0-rc1 is a prerelease and 1.2.* does not request one",
+						},
+					}))
+				})
+
+				it("excludes prereleases", func() {
+					resolver.PrereleasePolicy = libpak.PrereleaseExclude
+
+					_, err := resolver.Resolve("test-id", "1.2.0-rc1")
+					Expect(err.(libpak.NoValidDependenciesError).Rejections[1].Reasons).To(Equal([]libpak.RejectionReason{
+						{Constraint: libpak.RejectedPrerelease, Message: "version 1.2.0-rc1 is a prerelease"},
+					}))
+				})
+
+				it("includes prereleases", func() {
+					resolver.PrereleasePolicy = libpak.PrereleaseInclude
+
+					Expect(resolver.Resolve("test-id", "1.*")).To(Equal(resolver.Dependencies[1]))
+				})
+			})
+
+			context("channels", func() {
+				it.Before(func() {
+					resolver.Dependencies = []libpak.BuildpackDependency{
+						{
+							ID:       "test-id",
+							Name:     "test-name",
+							Version:  "11.0.8",
+							URI:      "test-uri",
+							SHA256:   "test-sha256",
+							Stacks:   []string{"test-stack-1"},
+							Channels: []string{"lts"},
+						},
+						{
+							ID:       "test-id",
+							Name:     "test-name",
+							Version:  "14.0.2",
+							URI:      "test-uri",
+							SHA256:   "test-sha256",
+							Stacks:   []string{"test-stack-1"},
+							Channels: []string{"latest"},
+						},
+						{
+							ID:       "test-id",
+							Name:     "test-name",
+							Version:  "15.0.0-ea",
+							URI:      "test-uri",
+							SHA256:   "test-sha256",
+							Stacks:   []string{"test-stack-1"},
+							Channels: []string{"ea"},
+						},
+					}
+					resolver.StackID = "test-stack-1"
+				})
+
+				it("resolves by channel", func() {
+					Expect(resolver.Resolve("test-id", "lts")).To(Equal(resolver.Dependencies[0]))
+					Expect(resolver.Resolve("test-id", "latest")).To(Equal(resolver.Dependencies[1]))
+					Expect(resolver.Resolve("test-id", "ea")).To(Equal(resolver.Dependencies[2]))
+				})
+
+				it("excludes prerelease channel members", func() {
+					resolver.PrereleasePolicy = libpak.PrereleaseExclude
+
+					_, err := resolver.Resolve("test-id", "ea")
+					Expect(err.(libpak.NoValidDependenciesError).Rejections[2].Reasons).To(Equal([]libpak.RejectionReason{
+						{Constraint: libpak.RejectedPrerelease, Message: "version 15.0.0-ea is a prerelease"},
+					}))
+				})
+
+				it("returns error for unknown channel", func() {
+					_, err := resolver.Resolve("test-id", "test-channel")
+					Expect(err).To(MatchError(HavePrefix("invalid constraint test-channel")))
+				})
+			})
+
+			context("tags", func() {
+				it.Before(func() {
+					resolver.Dependencies = []libpak.BuildpackDependency{
+						{
+							ID:      "test-id",
+							Name:    "test-name",
+							Version: "1.1",
+							URI:     "test-uri-jdk",
+							SHA256:  "test-sha256",
+							Stacks:  []string{"test-stack-1"},
+							Tags:    []string{"jdk", "test-distribution"},
+						},
+						{
+							ID:      "test-id",
+							Name:    "test-name",
+							Version: "1.1",
+							URI:     "test-uri-jre",
+							SHA256:  "test-sha256",
+							Stacks:  []string{"test-stack-1"},
+							Tags:    []string{"jre", "test-distribution"},
+						},
+					}
+					resolver.StackID = "test-stack-1"
+				})
+
+				it("filters by tags", func() {
+					Expect(resolver.Resolve("test-id", "1.*", "jre")).To(Equal(resolver.Dependencies[1]))
+					Expect(resolver.Resolve("test-id", "1.*", "test-distribution", "jdk")).To(Equal(resolver.Dependencies[0]))
+				})
+
+				it("indicates whether tagged dependency exists", func() {
+					Expect(resolver.Any("test-id", "", "jre")).To(BeTrue())
+					Expect(resolver.Any("test-id", "", "test-tag")).To(BeFalse())
+				})
+
+				it("describes tag rejections", func() {
+					_, err := resolver.Resolve("test-id", "1.*", "jre", "test-tag")
+					Expect(err).To(MatchError(strings.Join([]string{
+						"no valid dependencies for test-id, 1.*, [jre test-tag], and test-stack-1",
+						fmt.Sprintf("  %s", bard.FormatRejection("test-id", "1.1",
+							[]string{"tags [jre test-tag] are not all in [jdk test-distribution]"})),
+						fmt.Sprintf("  %s", bard.FormatRejection("test-id", "1.1",
+							[]string{"tags [jre test-tag] are not all in [jre test-distribution]"})),
+					}, "\n")))
+				})
+			})
+
 			it("returns the best dependency", func() {
 				resolver.Dependencies = []libpak.BuildpackDependency{
 					{
@@ -316,7 +565,73 @@ func testBuildpack(t *testing.T, context spec.G, it spec.S) {
 
 				_, err := resolver.Resolve("test-id-2", "1.0")
 				Expect(err).To(HaveOccurred())
-				Expect(err).To(MatchError(libpak.NoValidDependenciesError{Message: "no valid dependencies for test-id-2, 1.0, and test-stack-1 in [(test-id, 1.0, [test-stack-1 test-stack-2]) (test-id, 1.0, [test-stack-1 test-stack-3]) (test-id-2, 1.1, [test-stack-1 test-stack-3])]"}))
+				Expect(err).To(MatchError(libpak.NoValidDependenciesError{
+					Message: "no valid dependencies for test-id-2, 1.0, and test-stack-1",
+					ID:      "test-id-2",
+					Rejections: []libpak.DependencyRejection{
+						{
+							Dependency: resolver.Dependencies[2],
+							Reasons: []libpak.RejectionReason{
+								{Constraint: libpak.RejectedVersion, Message: "version 1.1 does not satisfy 1.0"},
+							},
+						},
+					},
+				}))
+			})
+
+			it("describes each rejected dependency", func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.0",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "2.0",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-2"},
+					},
+				}
+				resolver.StackID = "test-stack-1"
+
+				_, err := resolver.Resolve("test-id", "2.*")
+				Expect(err).To(MatchError(strings.Join([]string{
+					"no valid dependencies for test-id, 2.*, and test-stack-1",
+					fmt.Sprintf("  %s", bard.FormatRejection("test-id", "1.0", []string{"version 1.0 does not satisfy 2.*"})),
+					fmt.Sprintf("  %s", bard.FormatRejection("test-id", "2.0", []string{"stack test-stack-1 is not one of [test-stack-2]"})),
+				}, "\n")))
+			})
+
+			it("describes known ids if there are no dependencies with id", func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id-2",
+						Name:    "test-name",
+						Version: "1.0",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+					{
+						ID:      "test-id-1",
+						Name:    "test-name",
+						Version: "1.0",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+				}
+				resolver.StackID = "test-stack-1"
+
+				_, err := resolver.Resolve("test-id-3", "")
+				Expect(err).To(MatchError(
+					"no valid dependencies for test-id-3, *, and test-stack-1\n  no dependencies with id test-id-3 in [test-id-1 test-id-2]"))
 			})
 
 			it("substitutes all wildcard for unspecified version constraint", func() {
@@ -341,6 +656,250 @@ func testBuildpack(t *testing.T, context spec.G, it spec.S) {
 					Stacks:  []string{"test-stack-1", "test-stack-2"},
 				}))
 			})
+
+			it("ignores unparseable versions of other dependencies", func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id-1",
+						Name:    "test-name",
+						Version: "test-version",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+					{
+						ID:      "test-id-2",
+						Name:    "test-name",
+						Version: "1.1",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+				}
+				resolver.StackID = "test-stack-1"
+
+				Expect(resolver.Resolve("test-id-2", "")).To(Equal(libpak.BuildpackDependency{
+					ID:      "test-id-2",
+					Name:    "test-name",
+					Version: "1.1",
+					URI:     "test-uri",
+					SHA256:  "test-sha256",
+					Stacks:  []string{"test-stack-1"},
+				}))
+			})
+
+			it("returns error if version of dependency is unparseable", func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "test-version",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+				}
+				resolver.StackID = "test-stack-1"
+
+				_, err := resolver.Resolve("test-id", "")
+				Expect(err).To(MatchError(HavePrefix("unable to parse version test-version")))
+			})
+
+			it("uses version scheme for dependency id", func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.8.0_252",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.8.0_262",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "11.0.8+10",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+				}
+				resolver.StackID = "test-stack-1"
+				resolver.VersionSchemes = map[string]libpak.VersionScheme{
+					"test-id": libpak.SemverVersionScheme{
+						Normalizers: []libpak.VersionNormalizer{libpak.JavaVersionNormalizer},
+					},
+				}
+
+				Expect(resolver.Resolve("test-id", "8.*")).To(Equal(libpak.BuildpackDependency{
+					ID:      "test-id",
+					Name:    "test-name",
+					Version: "1.8.0_262",
+					URI:     "test-uri",
+					SHA256:  "test-sha256",
+					Stacks:  []string{"test-stack-1"},
+				}))
+
+				Expect(resolver.Resolve("test-id", "1.8.*")).To(Equal(libpak.BuildpackDependency{
+					ID:      "test-id",
+					Name:    "test-name",
+					Version: "1.8.0_262",
+					URI:     "test-uri",
+					SHA256:  "test-sha256",
+					Stacks:  []string{"test-stack-1"},
+				}))
+			})
+		})
+
+		context("ResolveAll", func() {
+			it.Before(func() {
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{ID: "test-id", Version: "11.0.7", Stacks: []string{"test-stack-1"}},
+					{ID: "test-id", Version: "8.0.252", Stacks: []string{"test-stack-1"}},
+					{ID: "test-id", Version: "11.1.0", Stacks: []string{"test-stack-1"}},
+					{ID: "test-id", Version: "11.0.8", Stacks: []string{"test-stack-1"}},
+					{ID: "test-id", Version: "14.0.2", Stacks: []string{"test-stack-2"}},
+				}
+				resolver.StackID = "test-stack-1"
+			})
+
+			it("returns all matching dependencies in order", func() {
+				Expect(resolver.ResolveAll("test-id", "")).To(Equal([]libpak.BuildpackDependency{
+					resolver.Dependencies[2],
+					resolver.Dependencies[3],
+					resolver.Dependencies[0],
+					resolver.Dependencies[1],
+				}))
+			})
+
+			it("returns error if there are no matching dependencies", func() {
+				_, err := resolver.ResolveAll("test-id", "14.*")
+				Expect(err).To(BeAssignableToTypeOf(libpak.NoValidDependenciesError{}))
+			})
+
+			it("groups by major line", func() {
+				deps, err := resolver.ResolveAll("test-id", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(resolver.GroupByLine(deps, libpak.MajorVersionLine)).To(Equal([]libpak.DependencyGroup{
+					{
+						Line:         "11",
+						Dependencies: []libpak.BuildpackDependency{resolver.Dependencies[2], resolver.Dependencies[3], resolver.Dependencies[0]},
+					},
+					{
+						Line:         "8",
+						Dependencies: []libpak.BuildpackDependency{resolver.Dependencies[1]},
+					},
+				}))
+			})
+
+			it("groups by minor line", func() {
+				deps, err := resolver.ResolveAll("test-id", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(resolver.GroupByLine(deps, libpak.MinorVersionLine)).To(Equal([]libpak.DependencyGroup{
+					{Line: "11.1", Dependencies: []libpak.BuildpackDependency{resolver.Dependencies[2]}},
+					{Line: "11.0", Dependencies: []libpak.BuildpackDependency{resolver.Dependencies[3], resolver.Dependencies[0]}},
+					{Line: "8.0", Dependencies: []libpak.BuildpackDependency{resolver.Dependencies[1]}},
+				}))
+			})
+		})
+
+		context("ResolveWithDefaults", func() {
+			var (
+				b *bytes.Buffer
+			)
+
+			it.Before(func() {
+				b = bytes.NewBuffer(nil)
+
+				resolver.DefaultVersions = map[string]string{"test-id": "1.*"}
+				resolver.Dependencies = []libpak.BuildpackDependency{
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "1.1",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "2.1",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+					{
+						ID:      "test-id",
+						Name:    "test-name",
+						Version: "3.1",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Stacks:  []string{"test-stack-1"},
+					},
+				}
+				resolver.Logger = bard.NewLogger(b)
+				resolver.StackID = "test-stack-1"
+			})
+
+			context("$TEST_VERSION", func() {
+				it.Before(func() {
+					Expect(os.Setenv("TEST_VERSION", "2.*")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("TEST_VERSION")).To(Succeed())
+				})
+
+				it("chooses environment variable", func() {
+					dep, source, err := resolver.ResolveWithDefaults("test-id", "TEST_VERSION",
+						libcnb.BuildpackPlanEntry{Version: "3.*"})
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(dep.Version).To(Equal("2.1"))
+					Expect(source).To(Equal(libpak.VersionSourceEnvironment))
+					Expect(b.String()).To(ContainSubstring("Using version 2.* from $TEST_VERSION"))
+				})
+			})
+
+			it("chooses entry", func() {
+				dep, source, err := resolver.ResolveWithDefaults("test-id", "TEST_VERSION",
+					libcnb.BuildpackPlanEntry{Version: "3.*"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dep.Version).To(Equal("3.1"))
+				Expect(source).To(Equal(libpak.VersionSourcePlan))
+				Expect(b.String()).To(ContainSubstring("Using version 3.* from buildpack plan"))
+			})
+
+			it("chooses default version", func() {
+				dep, source, err := resolver.ResolveWithDefaults("test-id", "TEST_VERSION", libcnb.BuildpackPlanEntry{})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dep.Version).To(Equal("1.1"))
+				Expect(source).To(Equal(libpak.VersionSourceDefault))
+				Expect(b.String()).To(ContainSubstring("Set $TEST_VERSION to configure the version"))
+			})
+
+			it("chooses no version", func() {
+				resolver.DefaultVersions = nil
+
+				dep, source, err := resolver.ResolveWithDefaults("test-id", "TEST_VERSION", libcnb.BuildpackPlanEntry{})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dep.Version).To(Equal("3.1"))
+				Expect(source).To(Equal(libpak.VersionSourceNone))
+			})
 		})
 
 		context("Any", func() {
@@ -22,10 +22,13 @@ import (
 	"os"
 	"regexp"
 
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/libpak"
 	"github.com/paketo-buildpacks/libpak/bard"
 	"github.com/paketo-buildpacks/libpak/internal"
 )
 
+// Deprecated: Dependency updates buildpack.toml with libpak.ReplaceBuildpackMetadata instead of these patterns.
 const (
 	DependencyPattern      = `(?m)(.*id[\s]+=[\s]+"%s"\n.*\nversion[\s]+=[\s]+")%s("\nuri[\s]+=[\s]+").*("\nsha256[\s]+=[\s]+").*(".*)`
 	DependencySubstitution = "${1}%s${2}%s${3}%s${4}"
@@ -61,23 +64,57 @@ func (d Dependency) Build(options ...Option) {
 		return
 	}
 
-	s := fmt.Sprintf(DependencyPattern, d.ID, d.VersionPattern)
+	var raw map[string]interface{}
+	if _, err := toml.Decode(string(c), &raw); err != nil {
+		config.exitHandler.Error(fmt.Errorf("unable to decode %s: %w", d.BuildpackPath, err))
+		return
+	}
+
+	metadata, err := libpak.NewBuildpackMetadata(metadataOf(raw))
+	if err != nil {
+		config.exitHandler.Error(fmt.Errorf("unable to decode metadata of %s: %w", d.BuildpackPath, err))
+		return
+	}
+
+	s := fmt.Sprintf("^%s$", d.VersionPattern)
 	r, err := regexp.Compile(s)
 	if err != nil {
 		config.exitHandler.Error(fmt.Errorf("unable to compile regex %s: %w", s, err))
 		return
 	}
 
-	if !r.Match(c) {
+	matched := false
+	for i, dep := range metadata.Dependencies {
+		if dep.ID != d.ID || !r.MatchString(dep.Version) {
+			continue
+		}
+
+		matched = true
+		metadata.Dependencies[i].Version = d.Version
+		metadata.Dependencies[i].URI = d.URI
+		metadata.Dependencies[i].SHA256 = d.SHA256
+	}
+
+	if !matched {
 		config.exitHandler.Error(fmt.Errorf("unable to match '%s' '%s'", d.ID, d.VersionPattern))
 		return
 	}
 
-	s = fmt.Sprintf(DependencySubstitution, d.Version, d.URI, d.SHA256)
-	c = r.ReplaceAll(c, []byte(s))
+	if c, err = libpak.ReplaceBuildpackMetadata(c, metadata); err != nil {
+		config.exitHandler.Error(fmt.Errorf("unable to replace metadata of %s: %w", d.BuildpackPath, err))
+		return
+	}
 
 	if err := ioutil.WriteFile(d.BuildpackPath, c, 0644); err != nil {
 		config.exitHandler.Error(fmt.Errorf("unable to write %s: %w", d.BuildpackPath, err))
 		return
 	}
 }
+
+func metadataOf(buildpack map[string]interface{}) map[string]interface{} {
+	if m, ok := buildpack["metadata"].(map[string]interface{}); ok {
+		return m
+	}
+
+	return map[string]interface{}{}
+}
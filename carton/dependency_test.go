@@ -45,8 +45,22 @@ func testDependency(t *testing.T, context spec.G, it spec.S) {
 		f, err := ioutil.TempFile("", "carton-dependency")
 		Expect(err).NotTo(HaveOccurred())
 
-		_, err = f.WriteString(`id      = "test-id"
+		_, err = f.WriteString(`api = "0.2"
+
+[metadata]
+pre-package = "scripts/build.sh"
+
+[[metadata.dependencies]]
+id      = "test-id"
 name    = "Test Name"
+version = "test-version-1" # test-comment
+uri     = "test-uri-1"
+sha256  = "test-sha256-1"
+stacks  = [ "test-stack" ]
+
+[[metadata.dependencies]]
+id      = "test-other-id"
+name    = "Test Other Name"
 version = "test-version-1"
 uri     = "test-uri-1"
 sha256  = "test-sha256-1"
@@ -71,14 +85,44 @@ stacks  = [ "test-stack" ]
 			VersionPattern: `test-version-[\d]`,
 		}
 
-		d.Build()
+		d.Build(carton.WithExitHandler(exitHandler))
 
-		Expect(ioutil.ReadFile(path)).To(Equal([]byte(`id      = "test-id"
+		Expect(exitHandler.Calls).To(BeEmpty())
+		Expect(ioutil.ReadFile(path)).To(Equal([]byte(`api = "0.2"
+
+[metadata]
+pre-package = "scripts/build.sh"
+
+[[metadata.dependencies]]
+id      = "test-id"
 name    = "Test Name"
-version = "test-version-2"
+version = "test-version-2" # test-comment
 uri     = "test-uri-2"
 sha256  = "test-sha256-2"
 stacks  = [ "test-stack" ]
+
+[[metadata.dependencies]]
+id      = "test-other-id"
+name    = "Test Other Name"
+version = "test-version-1"
+uri     = "test-uri-1"
+sha256  = "test-sha256-1"
+stacks  = [ "test-stack" ]
 `)))
 	})
+
+	it("fails if dependency does not match", func() {
+		d := carton.Dependency{
+			BuildpackPath:  path,
+			ID:             "test-id",
+			SHA256:         "test-sha256-2",
+			URI:            "test-uri-2",
+			Version:        "test-version-2",
+			VersionPattern: `test-version-[\d]-other`,
+		}
+
+		d.Build(carton.WithExitHandler(exitHandler))
+
+		Expect(exitHandler.Calls[0].Arguments.Get(0)).To(MatchError(`unable to match 'test-id' 'test-version-[\d]-other'`))
+	})
 }
@@ -36,6 +36,10 @@ import (
 // Package is an object that contains the configuration for building a package.
 type Package struct {
 
+	// Architectures are the CPU architectures to include dependencies for.  Dependencies without an architecture are
+	// always included.  If empty, dependencies for all architectures are included.
+	Architectures []string
+
 	// CacheLocation is the location to cache downloaded dependencies.
 	CacheLocation string
 
@@ -150,6 +154,11 @@ func (p Package) Build(options ...Option) {
 		}
 
 		for _, dep := range metadata.Dependencies {
+			if !p.includesArchitecture(dep.Arch) {
+				logger.Header("Skipping %s for %s", color.BlueString("%s %s", dep.Name, dep.Version), dep.Arch)
+				continue
+			}
+
 			logger.Header("Caching %s", color.BlueString("%s %s", dep.Name, dep.Version))
 
 			f, err := cache.Artifact(dep)
@@ -162,8 +171,8 @@ func (p Package) Build(options ...Option) {
 				return
 			}
 
-			entries[fmt.Sprintf("dependencies/%s/%s", dep.SHA256, filepath.Base(f.Name()))] = f.Name()
-			entries[fmt.Sprintf("dependencies/%s.toml", dep.SHA256)] = fmt.Sprintf("%s.toml", filepath.Dir(f.Name()))
+			entries[fmt.Sprintf("dependencies/%s/%s", dep.CacheKey(), filepath.Base(f.Name()))] = f.Name()
+			entries[fmt.Sprintf("dependencies/%s.toml", dep.CacheKey())] = fmt.Sprintf("%s.toml", filepath.Dir(f.Name()))
 		}
 	}
 
@@ -181,3 +190,17 @@ func (p Package) Build(options ...Option) {
 		}
 	}
 }
+
+func (p Package) includesArchitecture(arch string) bool {
+	if arch == "" || len(p.Architectures) == 0 {
+		return true
+	}
+
+	for _, a := range p.Architectures {
+		if a == arch {
+			return true
+		}
+	}
+
+	return false
+}
@@ -17,6 +17,7 @@
 package carton_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -161,4 +162,50 @@ include-files = [
 ]
 `)))
 	})
+	it("includes dependencies for architectures", func() {
+		Expect(ioutil.WriteFile(filepath.Join(path, "test-artifact"), []byte("test-fixture"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(path, "buildpack.toml"), []byte(fmt.Sprintf(`
+api = "0.0.0"
+
+[buildpack]
+name    = "test-name"
+version = "1.1.1"
+
+[[metadata.dependencies]]
+id      = "test-id"
+name    = "test-name"
+version = "1.1.1"
+uri     = "file://%[1]s/test-artifact"
+sha256  = "576dd8416de5619ea001d9662291d62444d1292a38e96956bc4651c01f14bca1"
+stacks  = [ "test-stack" ]
+arch    = "amd64"
+
+[[metadata.dependencies]]
+id      = "test-id"
+name    = "test-name"
+version = "1.1.1"
+uri     = "test-uri"
+sha256  = "test-sha256"
+stacks  = [ "test-stack" ]
+arch    = "arm64"
+`, path)), 0644)).To(Succeed())
+
+		p.Architectures = []string{"amd64"}
+		p.CacheLocation = filepath.Join(path, "cache")
+		p.Destination = "test-destination"
+		p.IncludeDependencies = true
+		p.Source = path
+
+		p.Build(
+			carton.WithEntryWriter(entryWriter),
+			carton.WithExecutor(executor),
+			carton.WithExitHandler(exitHandler))
+
+		Expect(exitHandler.Calls).To(BeEmpty())
+		Expect(entryWriter.Calls).To(HaveLen(2))
+		Expect(entryWriter.Calls[0].Arguments[1]).To(Equal(filepath.Join("test-destination", "dependencies",
+			"576dd8416de5619ea001d9662291d62444d1292a38e96956bc4651c01f14bca1-amd64.toml")))
+		Expect(entryWriter.Calls[1].Arguments[1]).To(Equal(filepath.Join("test-destination", "dependencies",
+			"576dd8416de5619ea001d9662291d62444d1292a38e96956bc4651c01f14bca1-amd64", "test-artifact")))
+	})
 }
@@ -29,6 +29,7 @@ func main() {
 	p := carton.Package{}
 
 	flagSet := pflag.NewFlagSet("Build Package", pflag.ExitOnError)
+	flagSet.StringSliceVar(&p.Architectures, "architecture", nil, "architectures to include dependencies for (default: all)")
 	flagSet.StringVar(&p.CacheLocation, "cache-location", "", "path to cache downloaded dependencies (default: $PWD/dependencies)")
 	flagSet.StringVar(&p.Destination, "destination", "", "path to the build package destination directory")
 	flagSet.BoolVar(&p.IncludeDependencies, "include-dependencies", true, "whether to include dependencies (default: true)")
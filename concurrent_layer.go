@@ -0,0 +1,201 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak/bard"
+)
+
+// ConcurrentContributionFunc contributes a layer, writing all of its output to logger.
+type ConcurrentContributionFunc func(layer libcnb.Layer, logger bard.Logger) (libcnb.Layer, error)
+
+// ConcurrentContribution is a layer contribution that can be run concurrently with other, independent, contributions.
+type ConcurrentContribution struct {
+
+	// Name is the name of the layer.
+	Name string
+
+	// Contribute contributes the layer.
+	Contribute ConcurrentContributionFunc
+}
+
+// NewConcurrentLayerContribution creates a new ConcurrentContribution that contributes a layer with a
+// LayerContributor.  f is called with the layer when it needs to be contributed.
+func NewConcurrentLayerContribution(name string, contributor LayerContributor,
+	f func(layer libcnb.Layer) (libcnb.Layer, error)) ConcurrentContribution {
+
+	return ConcurrentContribution{
+		Name: name,
+		Contribute: func(layer libcnb.Layer, logger bard.Logger) (libcnb.Layer, error) {
+			contributor.Logger = logger
+			return contributor.Contribute(layer, func() (libcnb.Layer, error) {
+				return f(layer)
+			})
+		},
+	}
+}
+
+// NewConcurrentDependencyLayerContribution creates a new ConcurrentContribution that contributes a layer with a
+// DependencyLayerContributor.  f is called with the layer and the dependency artifact when it needs to be contributed.
+func NewConcurrentDependencyLayerContribution(name string, contributor DependencyLayerContributor,
+	f func(layer libcnb.Layer, artifact *os.File) (libcnb.Layer, error)) ConcurrentContribution {
+
+	return ConcurrentContribution{
+		Name: name,
+		Contribute: func(layer libcnb.Layer, logger bard.Logger) (libcnb.Layer, error) {
+			contributor.LayerContributor.Logger = logger
+			contributor.DependencyCache.Logger = logger
+			return contributor.Contribute(layer, func(artifact *os.File) (libcnb.Layer, error) {
+				return f(layer, artifact)
+			})
+		},
+	}
+}
+
+// ConcurrentContributionError is the error returned by a single contribution run by ContributeConcurrently.
+type ConcurrentContributionError struct {
+
+	// Name is the name of the layer.
+	Name string
+
+	// Err is the nested error.
+	Err error
+}
+
+func (c ConcurrentContributionError) Error() string {
+	return fmt.Sprintf("%s: %s", c.Name, c.Err)
+}
+
+func (c ConcurrentContributionError) Unwrap() error {
+	return c.Err
+}
+
+// ConcurrentContributionErrors are the errors returned by each failed contribution run by ContributeConcurrently.
+type ConcurrentContributionErrors []ConcurrentContributionError
+
+func (c ConcurrentContributionErrors) Error() string {
+	s := []string{"unable to contribute layers"}
+	for _, e := range c {
+		s = append(s, fmt.Sprintf("  %s", e))
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// ContributeConcurrently runs contributions concurrently, at most parallelism at a time, or all at once if parallelism
+// is less than one.  The output of each contribution is buffered and written to logger in the order of contributions
+// once the contribution and all of its predecessors have completed.  If any contributions fail, all of the failures are
+// returned as ConcurrentContributionErrors.  Otherwise libcnb.LayerContributors that return the contributed layers are
+// returned, in the order of contributions, to be added to libcnb.BuildResult.Layers.
+func ContributeConcurrently(layers libcnb.Layers, logger bard.Logger, parallelism int,
+	contributions ...ConcurrentContribution) ([]libcnb.LayerContributor, error) {
+
+	if parallelism < 1 || parallelism > len(contributions) {
+		parallelism = len(contributions)
+	}
+
+	type result struct {
+		buffer *bytes.Buffer
+		done   chan struct{}
+		err    error
+		layer  libcnb.Layer
+	}
+
+	results := make([]*result, len(contributions))
+	for i := range results {
+		results[i] = &result{buffer: &bytes.Buffer{}, done: make(chan struct{})}
+	}
+
+	var (
+		semaphore = make(chan struct{}, parallelism)
+		wg        sync.WaitGroup
+	)
+
+	for i, c := range contributions {
+		wg.Add(1)
+
+		go func(c ConcurrentContribution, r *result) {
+			defer wg.Done()
+			defer close(r.done)
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			var options []bard.Option
+			if logger.IsDebugEnabled() {
+				options = append(options, bard.WithDebug(r.buffer))
+			}
+
+			layer, err := layers.Layer(c.Name)
+			if err != nil {
+				r.err = fmt.Errorf("unable to create layer %s: %w", c.Name, err)
+				return
+			}
+
+			r.layer, r.err = c.Contribute(layer, bard.NewLoggerWithOptions(r.buffer, options...))
+		}(c, results[i])
+	}
+
+	var (
+		contributors []libcnb.LayerContributor
+		errs         ConcurrentContributionErrors
+	)
+
+	for i, r := range results {
+		<-r.done
+
+		if w := logger.InfoWriter(); w != nil {
+			_, _ = r.buffer.WriteTo(w)
+		}
+
+		if r.err != nil {
+			errs = append(errs, ConcurrentContributionError{Name: contributions[i].Name, Err: r.err})
+			continue
+		}
+
+		contributors = append(contributors, contributedLayer{name: contributions[i].Name, layer: r.layer})
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return contributors, nil
+}
+
+// contributedLayer is a libcnb.LayerContributor that returns a layer that has already been contributed.
+type contributedLayer struct {
+	name  string
+	layer libcnb.Layer
+}
+
+func (c contributedLayer) Contribute(libcnb.Layer) (libcnb.Layer, error) {
+	return c.layer, nil
+}
+
+func (c contributedLayer) Name() string {
+	return c.name
+}
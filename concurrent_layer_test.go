@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/sclevine/spec"
+)
+
+func testConcurrentLayer(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		b      *bytes.Buffer
+		layers libcnb.Layers
+		logger bard.Logger
+	)
+
+	it.Before(func() {
+		var err error
+
+		layers.Path, err = ioutil.TempDir("", "concurrent-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		b = bytes.NewBuffer(nil)
+		logger = bard.NewLogger(b)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(layers.Path)).To(Succeed())
+	})
+
+	contribution := func(name string, delay time.Duration, err error) libpak.ConcurrentContribution {
+		return libpak.ConcurrentContribution{
+			Name: name,
+			Contribute: func(layer libcnb.Layer, logger bard.Logger) (libcnb.Layer, error) {
+				logger.Header("%s: start", name)
+				time.Sleep(delay)
+				logger.Header("%s: end", name)
+				return layer, err
+			},
+		}
+	}
+
+	it("contributes layers", func() {
+		c, err := libpak.ContributeConcurrently(layers, logger, 0,
+			contribution("test-1", 20*time.Millisecond, nil),
+			contribution("test-2", 0, nil),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c).To(HaveLen(2))
+		Expect(c[0].Name()).To(Equal("test-1"))
+		Expect(c[1].Name()).To(Equal("test-2"))
+
+		layer, err := c[1].Contribute(libcnb.Layer{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(layer.Name).To(Equal("test-2"))
+		Expect(layer.Path).To(Equal(filepath.Join(layers.Path, "test-2")))
+	})
+
+	it("logs in contribution order", func() {
+		_, err := libpak.ContributeConcurrently(layers, logger, 0,
+			contribution("test-1", 20*time.Millisecond, nil),
+			contribution("test-2", 0, nil),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(b.String()).To(MatchRegexp(`(?s)^\s*test-1: start\s+test-1: end\s+test-2: start\s+test-2: end\s*$`))
+	})
+
+	it("limits parallelism", func() {
+		var current, max int32
+
+		var contributions []libpak.ConcurrentContribution
+		for i := 0; i < 6; i++ {
+			contributions = append(contributions, libpak.ConcurrentContribution{
+				Name: fmt.Sprintf("test-%d", i),
+				Contribute: func(layer libcnb.Layer, logger bard.Logger) (libcnb.Layer, error) {
+					c := atomic.AddInt32(&current, 1)
+					for {
+						m := atomic.LoadInt32(&max)
+						if c <= m || atomic.CompareAndSwapInt32(&max, m, c) {
+							break
+						}
+					}
+
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&current, -1)
+					return layer, nil
+				},
+			})
+		}
+
+		_, err := libpak.ContributeConcurrently(layers, logger, 2, contributions...)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(max).To(BeNumerically("<=", 2))
+	})
+
+	it("aggregates errors", func() {
+		_, err := libpak.ContributeConcurrently(layers, logger, 0,
+			contribution("test-1", 0, fmt.Errorf("test-error-1")),
+			contribution("test-2", 0, nil),
+			contribution("test-3", 0, fmt.Errorf("test-error-3")),
+		)
+
+		Expect(err).To(MatchError("unable to contribute layers\n  test-1: test-error-1\n  test-3: test-error-3"))
+		Expect(b.String()).To(ContainSubstring("test-3: end"))
+	})
+
+	it("contributes with LayerContributor", func() {
+		lc := libpak.NewLayerContributor("test-name", map[string]interface{}{"alpha": "test-alpha"})
+
+		c, err := libpak.ContributeConcurrently(layers, logger, 0,
+			libpak.NewConcurrentLayerContribution("test-1", lc, func(layer libcnb.Layer) (libcnb.Layer, error) {
+				return layer, ioutil.WriteFile(filepath.Join(layer.Path, "test-file"), []byte{}, 0644)
+			}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(filepath.Join(layers.Path, "test-1", "test-file")).To(BeARegularFile())
+		Expect(b.String()).To(ContainSubstring("Contributing"))
+
+		layer, err := c[0].Contribute(libcnb.Layer{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(layer.Metadata).To(HaveKeyWithValue("alpha", "test-alpha"))
+	})
+
+	it("contributes layers sharing a dependency", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+
+		// The second download of the shared dependency fails verification after the first has completed
+		var (
+			first    = make(chan struct{})
+			requests int32
+		)
+		server.RouteToHandler(http.MethodGet, "/test-path", func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				_, _ = w.Write([]byte("test-fixture"))
+				close(first)
+				return
+			}
+
+			<-first
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte("test-other-fixture"))
+		})
+
+		download, err := ioutil.TempDir("", "concurrent-layer-download")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(download)
+
+		dependency := libpak.BuildpackDependency{
+			ID:      "test-id",
+			Name:    "test-name",
+			Version: "1.1.1",
+			URI:     fmt.Sprintf("%s/test-path", server.URL()),
+			SHA256:  "576dd8416de5619ea001d9662291d62444d1292a38e96956bc4651c01f14bca1",
+			Stacks:  []string{"test-stack"},
+		}
+
+		contribution := func(name string) libpak.ConcurrentContribution {
+			dlc := libpak.DependencyLayerContributor{
+				Dependency:       dependency,
+				DependencyCache:  libpak.DependencyCache{CachePath: download, DownloadPath: download},
+				LayerContributor: libpak.NewLayerContributor(name, dependency),
+			}
+
+			return libpak.NewConcurrentDependencyLayerContribution(name, dlc,
+				func(layer libcnb.Layer, artifact *os.File) (libcnb.Layer, error) {
+					defer artifact.Close()
+
+					b, err := ioutil.ReadAll(artifact)
+					if err != nil {
+						return libcnb.Layer{}, err
+					}
+
+					return layer, ioutil.WriteFile(filepath.Join(layer.Path, "test-artifact"), b, 0644)
+				})
+		}
+
+		_, err = libpak.ContributeConcurrently(layers, logger, 0, contribution("test-1"), contribution("test-2"))
+		Expect(err).To(BeAssignableToTypeOf(libpak.ConcurrentContributionErrors{}))
+
+		errs := err.(libpak.ConcurrentContributionErrors)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Err).To(MatchError(ContainSubstring("does not match expected")))
+
+		succeeded := "test-1"
+		if errs[0].Name == "test-1" {
+			succeeded = "test-2"
+		}
+		Expect(ioutil.ReadFile(filepath.Join(layers.Path, succeeded, "test-artifact"))).To(Equal([]byte("test-fixture")))
+		Expect(ioutil.ReadFile(filepath.Join(download, dependency.CacheKey(), "test-path"))).To(Equal([]byte("test-fixture")))
+
+		Expect(filepath.Glob(filepath.Join(download, dependency.CacheKey(), ".*"))).To(BeEmpty())
+		Expect(filepath.Glob(filepath.Join(download, ".*"))).To(BeEmpty())
+	})
+}
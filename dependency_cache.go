@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -66,8 +67,12 @@ func NewDependencyCache(buildpack libcnb.Buildpack) DependencyCache {
 // 2. DownloadPath
 // 3. Download from URI
 //
+// Artifacts are stored by the BuildpackDependency's CacheKey, so that artifacts for different platforms are kept apart.
 // If the BuildpackDependency's SHA256 is not set, the download can never be verified to be up to date and will always
 // download, skipping all of the caches.
+//
+// Downloads are written to a temporary file and moved into place only once verified, so concurrent calls for the same
+// dependency never observe partial or unverified artifacts.
 func (d *DependencyCache) Artifact(dependency BuildpackDependency) (*os.File, error) {
 	var (
 		actual   BuildpackDependency
@@ -81,63 +86,82 @@ func (d *DependencyCache) Artifact(dependency BuildpackDependency) (*os.File, er
 
 		d.Logger.Body("%s from %s", color.YellowString("Downloading"), dependency.URI)
 		artifact = filepath.Join(d.DownloadPath, filepath.Base(dependency.URI))
-		if err := d.download(dependency.URI, artifact); err != nil {
+		if err := d.download(dependency.URI, artifact, ""); err != nil {
 			return nil, fmt.Errorf("unable to download %s: %w", dependency.URI, err)
 		}
 
 		return os.Open(artifact)
 	}
 
-	file = filepath.Join(d.CachePath, fmt.Sprintf("%s.toml", dependency.SHA256))
+	key := dependency.CacheKey()
+
+	file = filepath.Join(d.CachePath, fmt.Sprintf("%s.toml", key))
 	if _, err := toml.DecodeFile(file, &actual); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("unable to decode download metadata %s: %w", file, err)
 	}
 
 	if reflect.DeepEqual(dependency, actual) {
 		d.Logger.Body("%s cached download from buildpack", color.GreenString("Reusing"))
-		return os.Open(filepath.Join(d.CachePath, dependency.SHA256, filepath.Base(dependency.URI)))
+		return os.Open(filepath.Join(d.CachePath, key, filepath.Base(dependency.URI)))
 	}
 
-	file = filepath.Join(d.DownloadPath, fmt.Sprintf("%s.toml", dependency.SHA256))
+	file = filepath.Join(d.DownloadPath, fmt.Sprintf("%s.toml", key))
 	if _, err := toml.DecodeFile(file, &actual); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("unable to decode download metadata %s: %w", file, err)
 	}
 
 	if reflect.DeepEqual(dependency, actual) {
 		d.Logger.Body("%s previously cached download", color.GreenString("Reusing"))
-		return os.Open(filepath.Join(d.DownloadPath, dependency.SHA256, filepath.Base(dependency.URI)))
+		return os.Open(filepath.Join(d.DownloadPath, key, filepath.Base(dependency.URI)))
 	}
 
 	d.Logger.Body("%s from %s", color.YellowString("Downloading"), dependency.URI)
-	artifact = filepath.Join(d.DownloadPath, dependency.SHA256, filepath.Base(dependency.URI))
-	if err := d.download(dependency.URI, artifact); err != nil {
+	artifact = filepath.Join(d.DownloadPath, key, filepath.Base(dependency.URI))
+	if err := d.download(dependency.URI, artifact, dependency.SHA256); err != nil {
 		return nil, fmt.Errorf("unable to download %s: %w", dependency.URI, err)
 	}
 
-	d.Logger.Body("Verifying checksum")
-	if err := d.verify(artifact, dependency.SHA256); err != nil {
+	file = filepath.Join(d.DownloadPath, fmt.Sprintf("%s.toml", key))
+	if err := d.writeMetadata(file, dependency); err != nil {
 		return nil, err
 	}
 
-	file = filepath.Join(d.DownloadPath, fmt.Sprintf("%s.toml", dependency.SHA256))
+	return os.Open(artifact)
+}
+
+// writeMetadata writes the metadata of a downloaded dependency to a temporary file and renames it into place so that
+// concurrent downloads of the same dependency never observe partially written metadata.
+func (DependencyCache) writeMetadata(file string, dependency BuildpackDependency) error {
 	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
-		return nil, fmt.Errorf("unable to make directory %s: %w", filepath.Dir(file), err)
+		return fmt.Errorf("unable to make directory %s: %w", filepath.Dir(file), err)
 	}
 
-	out, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	out, err := ioutil.TempFile(filepath.Dir(file), fmt.Sprintf(".%s-", filepath.Base(file)))
 	if err != nil {
-		return nil, fmt.Errorf("unable to open file %s: %w", file, err)
+		return fmt.Errorf("unable to create temporary file for %s: %w", file, err)
 	}
+	defer os.Remove(out.Name())
 	defer out.Close()
 
 	if err := toml.NewEncoder(out).Encode(dependency); err != nil {
-		return nil, fmt.Errorf("unable to write metadata %s: %w", file, err)
+		return fmt.Errorf("unable to write metadata %s: %w", file, err)
 	}
 
-	return os.Open(artifact)
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("unable to close %s: %w", out.Name(), err)
+	}
+
+	if err := os.Rename(out.Name(), file); err != nil {
+		return fmt.Errorf("unable to move %s to %s: %w", out.Name(), file, err)
+	}
+
+	return nil
 }
 
-func (d DependencyCache) download(uri string, destination string) error {
+// download downloads uri to a temporary file alongside destination, verifies it against expected if it is set, and
+// renames it into place.  Concurrent downloads to the same destination therefore never observe each other's partial
+// contents.
+func (d DependencyCache) download(uri string, destination string, expected string) error {
 	req, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
 		return fmt.Errorf("unable to create new GET request for %s: %w", uri, err)
@@ -165,14 +189,34 @@ func (d DependencyCache) download(uri string, destination string) error {
 		return fmt.Errorf("unable to make directory %s: %w", filepath.Dir(destination), err)
 	}
 
-	out, err := os.OpenFile(destination, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	out, err := ioutil.TempFile(filepath.Dir(destination), fmt.Sprintf(".%s-", filepath.Base(destination)))
 	if err != nil {
-		return fmt.Errorf("unable to open file %s: %w", destination, err)
+		return fmt.Errorf("unable to create temporary file for %s: %w", destination, err)
 	}
+	defer os.Remove(out.Name())
 	defer out.Close()
 
 	if _, err := io.Copy(out, resp.Body); err != nil {
-		return fmt.Errorf("unable to copy from %s to %s: %w", uri, destination, err)
+		return fmt.Errorf("unable to copy from %s to %s: %w", uri, out.Name(), err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("unable to close %s: %w", out.Name(), err)
+	}
+
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		return fmt.Errorf("unable to chmod %s: %w", out.Name(), err)
+	}
+
+	if expected != "" {
+		d.Logger.Body("Verifying checksum")
+		if err := d.verify(out.Name(), expected); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(out.Name(), destination); err != nil {
+		return fmt.Errorf("unable to move %s to %s: %w", out.Name(), destination, err)
 	}
 
 	return nil
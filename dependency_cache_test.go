@@ -126,6 +126,20 @@ func testDependencyCache(t *testing.T, context spec.G, it spec.S) {
 		Expect(ioutil.ReadAll(a)).To(Equal([]byte("test-fixture")))
 	})
 
+	it("keeps artifacts for different architectures apart", func() {
+		copyFile(filepath.Join("testdata", "test-file"), filepath.Join(cachePath, dependency.SHA256, "test-path"))
+		writeTOML(filepath.Join(cachePath, fmt.Sprintf("%s.toml", dependency.SHA256)), dependency)
+
+		dependency.Arch = "arm64"
+		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "test-fixture"))
+
+		a, err := dependencyCache.Artifact(dependency)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a.Name()).To(Equal(filepath.Join(downloadPath, fmt.Sprintf("%s-arm64", dependency.SHA256), "test-path")))
+		Expect(filepath.Join(downloadPath, fmt.Sprintf("%s-arm64.toml", dependency.SHA256))).To(BeARegularFile())
+	})
+
 	it("downloads", func() {
 		server.AppendHandlers(ghttp.RespondWith(http.StatusOK, "test-fixture"))
 
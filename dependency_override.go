@@ -0,0 +1,254 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpacks/libcnb"
+	"github.com/heroku/color"
+)
+
+const (
+	// DependencyOverridesBindingKind is the kind of binding whose secret values contain dependency overrides.
+	DependencyOverridesBindingKind = "dependency-overrides"
+
+	// DependencyOverridesEnvironmentVariable is the environment variable that names a file containing dependency
+	// overrides.
+	DependencyOverridesEnvironmentVariable = "BP_DEPENDENCY_OVERRIDES"
+)
+
+// DependencyOverride is a user-supplied replacement for, or addition to, the dependencies known to a buildpack.
+type DependencyOverride struct {
+
+	// ID is the ID of the dependency to override.
+	ID string `toml:"id"`
+
+	// Match is a version constraint limiting which dependencies with ID are overridden.  If not set, all dependencies
+	// with ID are overridden.
+	Match string `toml:"match"`
+
+	// Name is the dependency name, used only when adding a new dependency.
+	Name string `toml:"name"`
+
+	// Version is the replacement dependency version.
+	Version string `toml:"version"`
+
+	// URI is the replacement dependency URI.
+	URI string `toml:"uri"`
+
+	// SHA256 is the replacement hash of the dependency.
+	SHA256 string `toml:"sha256"`
+
+	// AllowUnverified allows the override to replace the URI of a dependency, or add a dependency, without a SHA256.
+	// The download of such a dependency cannot be verified.
+	AllowUnverified bool `toml:"allow-unverified"`
+
+	// Stacks are the stacks a new dependency is compatible with, used only when adding a new dependency.  Defaults to
+	// the stack of the build if not set.
+	Stacks []string `toml:"stacks"`
+
+	// Source is a user readable description of where the override was declared.
+	Source string `toml:"-"`
+}
+
+// NewDependencyOverrides reads dependency overrides from the file named by $BP_DEPENDENCY_OVERRIDES and from the
+// secret values of any binding with kind dependency-overrides.  Each source is a TOML document containing
+// [[dependencies]] tables.
+func NewDependencyOverrides(bindings libcnb.Bindings) ([]DependencyOverride, error) {
+	var overrides []DependencyOverride
+
+	if file, ok := os.LookupEnv(DependencyOverridesEnvironmentVariable); ok {
+		var raw struct {
+			Dependencies []DependencyOverride `toml:"dependencies"`
+		}
+
+		if _, err := toml.DecodeFile(file, &raw); err != nil {
+			return nil, fmt.Errorf("unable to decode dependency overrides %s: %w", file, err)
+		}
+
+		for _, o := range raw.Dependencies {
+			o.Source = file
+			overrides = append(overrides, o)
+		}
+	}
+
+	var names []string
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		binding := bindings[name]
+		if binding.Metadata[libcnb.BindingKind] != DependencyOverridesBindingKind &&
+			binding.Metadata[BindingType] != DependencyOverridesBindingKind {
+			continue
+		}
+
+		var keys []string
+		for k := range binding.Secret {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			var raw struct {
+				Dependencies []DependencyOverride `toml:"dependencies"`
+			}
+
+			if _, err := toml.Decode(binding.Secret[k], &raw); err != nil {
+				return nil, fmt.Errorf("unable to decode dependency overrides from binding %s/%s: %w", name, k, err)
+			}
+
+			for _, o := range raw.Dependencies {
+				o.Source = fmt.Sprintf("binding %s", name)
+				overrides = append(overrides, o)
+			}
+		}
+	}
+
+	return overrides, nil
+}
+
+// ApplyOverrides applies overrides, in order, to the collection of Dependencies.  An override replaces the version,
+// URI, and SHA256, when set, of every dependency with the same ID whose version satisfies the override's Match
+// constraint.  If no dependency matches, the override is added as a new dependency.  An override that replaces the URI
+// of a dependency, or adds a dependency, must specify a SHA256 unless it sets AllowUnverified, in which case the
+// download is not verified.  Every overridden dependency is marked as Overridden and logged.
+func (d *DependencyResolver) ApplyOverrides(overrides []DependencyOverride) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	d.Dependencies = append([]BuildpackDependency{}, d.Dependencies...)
+
+	for _, o := range overrides {
+		if o.ID == "" {
+			return fmt.Errorf("dependency override from %s has no id", o.Source)
+		}
+
+		var vc VersionConstraint
+		if o.Match != "" {
+			var err error
+			if vc, err = d.scheme(o.ID).ParseConstraint(o.Match); err != nil {
+				return fmt.Errorf("invalid match constraint %s in dependency override from %s: %w", o.Match, o.Source, err)
+			}
+		}
+
+		matched := false
+		for i, c := range d.Dependencies {
+			if c.ID != o.ID {
+				continue
+			}
+
+			if vc != nil {
+				v, err := d.scheme(c.ID).ParseVersion(c.Version)
+				if err != nil {
+					return fmt.Errorf("unable to parse version %s: %w", c.Version, err)
+				}
+
+				if !vc.Check(v) {
+					continue
+				}
+			}
+
+			matched = true
+			original := c
+
+			if o.Version != "" {
+				c.Version = o.Version
+			}
+
+			if o.URI != "" && o.URI != c.URI {
+				c.URI = o.URI
+
+				if o.SHA256 == "" {
+					if err := d.allowUnverified(o); err != nil {
+						return err
+					}
+					c.SHA256 = ""
+				}
+			}
+
+			if o.SHA256 != "" {
+				c.SHA256 = o.SHA256
+			}
+
+			c.Overridden = true
+			d.Dependencies[i] = c
+
+			d.Logger.Header("%s Overriding %s %s with %s from %s (%s)",
+				color.New(color.FgYellow, color.Bold).Sprint("Warning:"), original.ID, original.Version, c.Version, c.URI, o.Source)
+		}
+
+		if matched {
+			continue
+		}
+
+		if o.Version == "" || o.URI == "" {
+			return fmt.Errorf("dependency override for %s from %s matches no dependency and must specify a version and uri",
+				o.ID, o.Source)
+		}
+
+		if o.SHA256 == "" {
+			if err := d.allowUnverified(o); err != nil {
+				return err
+			}
+		}
+
+		c := BuildpackDependency{
+			ID:         o.ID,
+			Name:       o.Name,
+			Version:    o.Version,
+			URI:        o.URI,
+			SHA256:     o.SHA256,
+			Stacks:     o.Stacks,
+			Overridden: true,
+		}
+
+		if c.Name == "" {
+			c.Name = c.ID
+		}
+
+		if len(c.Stacks) == 0 {
+			c.Stacks = []string{d.StackID}
+		}
+
+		d.Dependencies = append(d.Dependencies, c)
+
+		d.Logger.Header("%s Adding %s %s from %s (%s)",
+			color.New(color.FgYellow, color.Bold).Sprint("Warning:"), c.ID, c.Version, c.URI, o.Source)
+	}
+
+	return nil
+}
+
+// allowUnverified returns an error if an override without a SHA256 has not opted in to unverified downloads.
+func (d *DependencyResolver) allowUnverified(override DependencyOverride) error {
+	if !override.AllowUnverified {
+		return fmt.Errorf("dependency override for %s from %s has a uri without a sha256 and does not set allow-unverified",
+			override.ID, override.Source)
+	}
+
+	d.Logger.Header("%s Dependency override for %s from %s has no sha256. Download cannot be verified.",
+		color.New(color.FgYellow, color.Bold).Sprint("Warning:"), override.ID, override.Source)
+	return nil
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/sclevine/spec"
+)
+
+func testDependencyOverride(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("NewDependencyOverrides", func() {
+		var (
+			path string
+		)
+
+		it.Before(func() {
+			var err error
+
+			path, err = ioutil.TempDir("", "dependency-override")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(path)).To(Succeed())
+		})
+
+		it("returns no overrides", func() {
+			Expect(libpak.NewDependencyOverrides(libcnb.Bindings{})).To(BeEmpty())
+		})
+
+		context("$BP_DEPENDENCY_OVERRIDES", func() {
+			it.Before(func() {
+				Expect(ioutil.WriteFile(filepath.Join(path, "overrides.toml"), []byte(`
+[[dependencies]]
+id      = "test-id"
+version = "1.1.1"
+uri     = "test-uri"
+sha256  = "test-sha256"
+`), 0644)).To(Succeed())
+
+				Expect(os.Setenv("BP_DEPENDENCY_OVERRIDES", filepath.Join(path, "overrides.toml"))).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_DEPENDENCY_OVERRIDES")).To(Succeed())
+			})
+
+			it("reads overrides from file", func() {
+				Expect(libpak.NewDependencyOverrides(libcnb.Bindings{})).To(Equal([]libpak.DependencyOverride{
+					{
+						ID:      "test-id",
+						Version: "1.1.1",
+						URI:     "test-uri",
+						SHA256:  "test-sha256",
+						Source:  filepath.Join(path, "overrides.toml"),
+					},
+				}))
+			})
+		})
+
+		it("reads overrides from binding", func() {
+			bindings := libcnb.Bindings{
+				"test-binding-1": libcnb.Binding{
+					Metadata: map[string]string{libcnb.BindingKind: "dependency-overrides"},
+					Secret: map[string]string{"overrides.toml": `
+[[dependencies]]
+id  = "test-id"
+uri = "test-uri"
+`},
+				},
+				"test-binding-2": libcnb.Binding{
+					Metadata: map[string]string{libcnb.BindingKind: "test-kind"},
+					Secret:   map[string]string{"overrides.toml": "invalid"},
+				},
+			}
+
+			Expect(libpak.NewDependencyOverrides(bindings)).To(Equal([]libpak.DependencyOverride{
+				{
+					ID:     "test-id",
+					URI:    "test-uri",
+					Source: "binding test-binding-1",
+				},
+			}))
+		})
+	})
+
+	context("ApplyOverrides", func() {
+		var (
+			resolver libpak.DependencyResolver
+		)
+
+		it.Before(func() {
+			resolver.Dependencies = []libpak.BuildpackDependency{
+				{
+					ID:      "test-id",
+					Name:    "test-name",
+					Version: "1.1",
+					URI:     "test-uri-1",
+					SHA256:  "test-sha256-1",
+					Stacks:  []string{"test-stack"},
+				},
+				{
+					ID:      "test-id",
+					Name:    "test-name",
+					Version: "2.1",
+					URI:     "test-uri-2",
+					SHA256:  "test-sha256-2",
+					Stacks:  []string{"test-stack"},
+				},
+			}
+			resolver.StackID = "test-stack"
+		})
+
+		it("replaces matching dependencies", func() {
+			Expect(resolver.ApplyOverrides([]libpak.DependencyOverride{
+				{ID: "test-id", Match: "2.*", URI: "test-uri-3", SHA256: "test-sha256-3"},
+			})).To(Succeed())
+
+			Expect(resolver.Dependencies).To(Equal([]libpak.BuildpackDependency{
+				{
+					ID:      "test-id",
+					Name:    "test-name",
+					Version: "1.1",
+					URI:     "test-uri-1",
+					SHA256:  "test-sha256-1",
+					Stacks:  []string{"test-stack"},
+				},
+				{
+					ID:         "test-id",
+					Name:       "test-name",
+					Version:    "2.1",
+					URI:        "test-uri-3",
+					SHA256:     "test-sha256-3",
+					Stacks:     []string{"test-stack"},
+					Overridden: true,
+				},
+			}))
+		})
+
+		it("returns error if URI is replaced without SHA256", func() {
+			Expect(resolver.ApplyOverrides([]libpak.DependencyOverride{
+				{ID: "test-id", Match: "2.*", URI: "test-uri-3", Source: "test-source"},
+			})).To(MatchError("dependency override for test-id from test-source has a uri without a sha256 and does not set allow-unverified"))
+		})
+
+		it("clears SHA256 if URI is replaced without SHA256 and unverified downloads are allowed", func() {
+			Expect(resolver.ApplyOverrides([]libpak.DependencyOverride{
+				{ID: "test-id", Match: "2.*", URI: "test-uri-3", AllowUnverified: true},
+			})).To(Succeed())
+
+			Expect(resolver.Dependencies[1]).To(Equal(libpak.BuildpackDependency{
+				ID:         "test-id",
+				Name:       "test-name",
+				Version:    "2.1",
+				URI:        "test-uri-3",
+				Stacks:     []string{"test-stack"},
+				Overridden: true,
+			}))
+		})
+
+		it("keeps SHA256 if only version is replaced", func() {
+			Expect(resolver.ApplyOverrides([]libpak.DependencyOverride{
+				{ID: "test-id", Match: "2.*", Version: "2.2"},
+			})).To(Succeed())
+
+			Expect(resolver.Dependencies[1].URI).To(Equal("test-uri-2"))
+			Expect(resolver.Dependencies[1].SHA256).To(Equal("test-sha256-2"))
+		})
+
+		it("adds new dependencies", func() {
+			Expect(resolver.ApplyOverrides([]libpak.DependencyOverride{
+				{ID: "test-id-2", Version: "3.1", URI: "test-uri-3", SHA256: "test-sha256-3"},
+			})).To(Succeed())
+
+			Expect(resolver.Resolve("test-id-2", "")).To(Equal(libpak.BuildpackDependency{
+				ID:         "test-id-2",
+				Name:       "test-id-2",
+				Version:    "3.1",
+				URI:        "test-uri-3",
+				SHA256:     "test-sha256-3",
+				Stacks:     []string{"test-stack"},
+				Overridden: true,
+			}))
+		})
+
+		it("returns error if new dependency has no SHA256", func() {
+			Expect(resolver.ApplyOverrides([]libpak.DependencyOverride{
+				{ID: "test-id-2", Version: "3.1", URI: "test-uri-3", Source: "test-source"},
+			})).To(MatchError("dependency override for test-id-2 from test-source has a uri without a sha256 and does not set allow-unverified"))
+		})
+
+		it("returns error if new dependency is incomplete", func() {
+			Expect(resolver.ApplyOverrides([]libpak.DependencyOverride{
+				{ID: "test-id-2", URI: "test-uri-3", Source: "test-source"},
+			})).To(MatchError("dependency override for test-id-2 from test-source matches no dependency and must specify a version and uri"))
+		})
+	})
+}
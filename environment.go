@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/buildpacks/libcnb"
+)
+
+// EnvironmentOperation is an operation applied to an environment variable.
+type EnvironmentOperation string
+
+const (
+	// EnvironmentAppend appends a value to any previous value of an environment variable.
+	EnvironmentAppend EnvironmentOperation = "append"
+
+	// EnvironmentDefault sets a value for an environment variable if it has no previous value.
+	EnvironmentDefault EnvironmentOperation = "default"
+
+	// EnvironmentOverride replaces any previous value of an environment variable.
+	EnvironmentOverride EnvironmentOperation = "override"
+
+	// EnvironmentPrepend prepends a value to any previous value of an environment variable.
+	EnvironmentPrepend EnvironmentOperation = "prepend"
+)
+
+var environmentOperations = []EnvironmentOperation{
+	EnvironmentAppend, EnvironmentDefault, EnvironmentOverride, EnvironmentPrepend,
+}
+
+// EnvironmentBuilder writes operations on environment variables into a layer's environment.  Only a single operation
+// may be applied to each environment variable with the exception of appending and prepending which may be combined if
+// they use the same delimiter.  Conflicting operations, including those written to the environment before the builder
+// was created, are rejected.  A variable written without an operation, as libcnb.Environment.PrependPath does,
+// conflicts with every operation.  Variables are logged, without their values, when the layer is written.
+type EnvironmentBuilder struct {
+
+	// Environment is the environment to write to.
+	Environment libcnb.Environment
+}
+
+// NewBuildEnvironmentBuilder creates a new EnvironmentBuilder that writes to the build environment of a layer.
+func NewBuildEnvironmentBuilder(layer *libcnb.Layer) EnvironmentBuilder {
+	if layer.BuildEnvironment == nil {
+		layer.BuildEnvironment = libcnb.Environment{}
+	}
+
+	return EnvironmentBuilder{Environment: layer.BuildEnvironment}
+}
+
+// NewLaunchEnvironmentBuilder creates a new EnvironmentBuilder that writes to the launch environment of a layer.
+func NewLaunchEnvironmentBuilder(layer *libcnb.Layer) EnvironmentBuilder {
+	if layer.LaunchEnvironment == nil {
+		layer.LaunchEnvironment = libcnb.Environment{}
+	}
+
+	return EnvironmentBuilder{Environment: layer.LaunchEnvironment}
+}
+
+// NewSharedEnvironmentBuilder creates a new EnvironmentBuilder that writes to the shared environment of a layer.
+func NewSharedEnvironmentBuilder(layer *libcnb.Layer) EnvironmentBuilder {
+	if layer.SharedEnvironment == nil {
+		layer.SharedEnvironment = libcnb.Environment{}
+	}
+
+	return EnvironmentBuilder{Environment: layer.SharedEnvironment}
+}
+
+// Append appends a value to an environment variable, separated from any previous value by delimiter.
+func (e EnvironmentBuilder) Append(name string, delimiter string, format string, a ...interface{}) error {
+	return e.write(name, EnvironmentAppend, delimiter, fmt.Sprintf(format, a...))
+}
+
+// AppendPath appends a value to a PATH-like environment variable, separated from any previous value by the OS path
+// list separator.
+func (e EnvironmentBuilder) AppendPath(name string, format string, a ...interface{}) error {
+	return e.Append(name, string(os.PathListSeparator), format, a...)
+}
+
+// Default sets a value for an environment variable that is used only if the variable has no previous value.
+func (e EnvironmentBuilder) Default(name string, format string, a ...interface{}) error {
+	return e.write(name, EnvironmentDefault, "", fmt.Sprintf(format, a...))
+}
+
+// Override sets a value for an environment variable that replaces any previous value.
+func (e EnvironmentBuilder) Override(name string, format string, a ...interface{}) error {
+	return e.write(name, EnvironmentOverride, "", fmt.Sprintf(format, a...))
+}
+
+// Prepend prepends a value to an environment variable, separated from any previous value by delimiter.
+func (e EnvironmentBuilder) Prepend(name string, delimiter string, format string, a ...interface{}) error {
+	return e.write(name, EnvironmentPrepend, delimiter, fmt.Sprintf(format, a...))
+}
+
+// PrependPath prepends a value to a PATH-like environment variable, separated from any previous value by the OS path
+// list separator.
+func (e EnvironmentBuilder) PrependPath(name string, format string, a ...interface{}) error {
+	return e.Prepend(name, string(os.PathListSeparator), format, a...)
+}
+
+func (e EnvironmentBuilder) write(name string, operation EnvironmentOperation, delimiter string, value string) error {
+	if name == "" || strings.ContainsAny(name, "=/") {
+		return fmt.Errorf("invalid environment variable name %q", name)
+	}
+
+	if _, ok := e.Environment[name]; ok {
+		return fmt.Errorf("unable to %s $%s: conflicts with existing value without an operation", operation, name)
+	}
+
+	for _, o := range environmentOperations {
+		if o == operation || (isConcatenation(o) && isConcatenation(operation)) {
+			continue
+		}
+
+		if _, ok := e.Environment[e.key(name, o)]; ok {
+			return fmt.Errorf("unable to %s $%s: conflicts with existing %s", operation, name, o)
+		}
+	}
+
+	if existing, ok := e.Environment[e.key(name, operation)]; ok && existing != value {
+		return fmt.Errorf("unable to %s $%s with %q: conflicts with existing %q", operation, name, value, existing)
+	}
+
+	if delimiter != "" {
+		key := fmt.Sprintf("%s.delim", name)
+
+		if existing, ok := e.Environment[key]; ok && existing != delimiter {
+			return fmt.Errorf("unable to %s $%s with delimiter %q: conflicts with existing delimiter %q",
+				operation, name, delimiter, existing)
+		}
+
+		e.Environment[key] = delimiter
+	}
+
+	e.Environment[e.key(name, operation)] = value
+
+	return nil
+}
+
+func (EnvironmentBuilder) key(name string, operation EnvironmentOperation) string {
+	return fmt.Sprintf("%s.%s", name, operation)
+}
+
+func isConcatenation(operation EnvironmentOperation) bool {
+	return operation == EnvironmentAppend || operation == EnvironmentPrepend
+}
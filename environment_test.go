@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/buildpacks/libcnb/mocks"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-buildpacks/libpak/internal"
+	"github.com/sclevine/spec"
+	"github.com/stretchr/testify/mock"
+)
+
+func testEnvironment(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		b     *bytes.Buffer
+		layer libcnb.Layer
+	)
+
+	it.Before(func() {
+		b = bytes.NewBuffer(nil)
+		layer = libcnb.Layer{}
+	})
+
+	it("creates environments for layer", func() {
+		Expect(libpak.NewBuildEnvironmentBuilder(&layer).Override("TEST_KEY", "test-build")).
+			To(Succeed())
+		Expect(libpak.NewLaunchEnvironmentBuilder(&layer).Override("TEST_KEY", "test-launch")).
+			To(Succeed())
+		Expect(libpak.NewSharedEnvironmentBuilder(&layer).Override("TEST_KEY", "test-shared")).
+			To(Succeed())
+
+		Expect(layer.BuildEnvironment).To(Equal(libcnb.Environment{"TEST_KEY.override": "test-build"}))
+		Expect(layer.LaunchEnvironment).To(Equal(libcnb.Environment{"TEST_KEY.override": "test-launch"}))
+		Expect(layer.SharedEnvironment).To(Equal(libcnb.Environment{"TEST_KEY.override": "test-shared"}))
+	})
+
+	context("EnvironmentBuilder", func() {
+		var (
+			e libpak.EnvironmentBuilder
+		)
+
+		it.Before(func() {
+			e = libpak.NewLaunchEnvironmentBuilder(&layer)
+		})
+
+		it("prepends path", func() {
+			Expect(e.PrependPath("PATH", "%s/bin", "test-path")).To(Succeed())
+
+			Expect(layer.LaunchEnvironment).To(Equal(libcnb.Environment{
+				"PATH.delim":   string(os.PathListSeparator),
+				"PATH.prepend": "test-path/bin",
+			}))
+		})
+
+		it("logs variables without values when written", func() {
+			buildpackPath, err := ioutil.TempDir("", "environment-buildpack")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(buildpackPath)
+			Expect(ioutil.WriteFile(filepath.Join(buildpackPath, "buildpack.toml"), []byte(`[buildpack]
+name    = "test-name"
+version = "test-version"`),
+				0644)).To(Succeed())
+
+			layersPath, err := ioutil.TempDir("", "environment-layers")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(layersPath)
+
+			platformPath, err := ioutil.TempDir("", "environment-platform")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(platformPath)
+
+			buildpackPlanPath := filepath.Join(platformPath, "plan.toml")
+			Expect(ioutil.WriteFile(buildpackPlanPath, []byte{}, 0644)).To(Succeed())
+
+			Expect(os.Setenv("CNB_STACK_ID", "test-stack-id")).To(Succeed())
+			defer os.Unsetenv("CNB_STACK_ID")
+
+			layer = libcnb.Layer{Name: "test-layer", Path: filepath.Join(layersPath, "test-layer")}
+			Expect(libpak.NewLaunchEnvironmentBuilder(&layer).PrependPath("PATH", "%s/bin", "test-path")).To(Succeed())
+
+			contributor := &mocks.LayerContributor{}
+			contributor.On("Name").Return("test-layer")
+			contributor.On("Contribute", mock.Anything).Return(layer, nil)
+
+			builder := &mocks.Builder{}
+			builder.On("Build", mock.Anything).
+				Return(libcnb.BuildResult{Layers: []libcnb.LayerContributor{contributor}}, nil)
+
+			exitHandler := &mocks.ExitHandler{}
+			exitHandler.On("Error", mock.Anything)
+
+			libpak.Build(builder,
+				libcnb.WithArguments([]string{filepath.Join(buildpackPath, "bin", "build"), layersPath, platformPath, buildpackPlanPath}),
+				libcnb.WithEnvironmentWriter(internal.NewEnvironmentWriter(internal.WithEnvironmentWriterLogger(bard.NewLogger(b)))),
+				libcnb.WithExitHandler(exitHandler),
+			)
+			Expect(exitHandler.Calls).To(BeEmpty())
+
+			Expect(filepath.Join(layersPath, "test-layer", "env.launch", "PATH.prepend")).To(BeARegularFile())
+			Expect(strings.Count(b.String(), "Writing env.launch/PATH.prepend")).To(Equal(1))
+			Expect(b.String()).NotTo(ContainSubstring("test-path"))
+		})
+
+		it("appends and prepends with the same delimiter", func() {
+			Expect(e.Append("TEST_KEY", " ", "test-append")).To(Succeed())
+			Expect(e.Prepend("TEST_KEY", " ", "test-prepend")).To(Succeed())
+
+			Expect(layer.LaunchEnvironment).To(Equal(libcnb.Environment{
+				"TEST_KEY.append":  "test-append",
+				"TEST_KEY.delim":   " ",
+				"TEST_KEY.prepend": "test-prepend",
+			}))
+		})
+
+		it("sets default", func() {
+			Expect(e.Default("TEST_KEY", "test-value")).To(Succeed())
+
+			Expect(layer.LaunchEnvironment).To(Equal(libcnb.Environment{"TEST_KEY.default": "test-value"}))
+		})
+
+		it("allows repeated identical operations", func() {
+			Expect(e.Override("TEST_KEY", "test-value")).To(Succeed())
+			Expect(e.Override("TEST_KEY", "test-value")).To(Succeed())
+		})
+
+		it("rejects conflicting values", func() {
+			Expect(e.Override("TEST_KEY", "test-value-1")).To(Succeed())
+			Expect(e.Override("TEST_KEY", "test-value-2")).
+				To(MatchError(`unable to override $TEST_KEY with "test-value-2": conflicts with existing "test-value-1"`))
+		})
+
+		it("rejects conflicting operations", func() {
+			Expect(e.Default("TEST_KEY", "test-value")).To(Succeed())
+			Expect(e.PrependPath("TEST_KEY", "test-value")).
+				To(MatchError("unable to prepend $TEST_KEY: conflicts with existing default"))
+		})
+
+		it("rejects conflicting operations written before builder", func() {
+			layer.LaunchEnvironment.Override("TEST_KEY", "test-value")
+
+			Expect(e.Append("TEST_KEY", ":", "test-value")).
+				To(MatchError("unable to append $TEST_KEY: conflicts with existing override"))
+		})
+
+		it("rejects operations on values written without an operation", func() {
+			layer.LaunchEnvironment.PrependPath("TEST_KEY", "test-value")
+
+			Expect(e.PrependPath("TEST_KEY", "test-value")).
+				To(MatchError("unable to prepend $TEST_KEY: conflicts with existing value without an operation"))
+		})
+
+		it("rejects conflicting delimiters", func() {
+			Expect(e.Append("TEST_KEY", ":", "test-append")).To(Succeed())
+			Expect(e.Prepend("TEST_KEY", " ", "test-prepend")).
+				To(MatchError(`unable to prepend $TEST_KEY with delimiter " ": conflicts with existing delimiter ":"`))
+		})
+
+		it("rejects invalid names", func() {
+			Expect(e.Override("TEST=KEY", "test-value")).To(MatchError(`invalid environment variable name "TEST=KEY"`))
+		})
+	})
+}
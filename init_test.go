@@ -28,10 +28,17 @@ func TestUnit(t *testing.T) {
 	suite("Binding", testBinding)
 	suite("Build", testBuild)
 	suite("Buildpack", testBuildpack)
+	suite("BuildpackEncoder", testBuildpackEncoder)
 	suite("BuildpackPlan", testBuildpackPlan)
+	suite("ConcurrentLayer", testConcurrentLayer)
 	suite("Detect", testDetect)
 	suite("DependencyCache", testDependencyCache)
+	suite("DependencyOverride", testDependencyOverride)
+	suite("Environment", testEnvironment)
 	suite("Formatter", testFormatter)
 	suite("Layer", testLayer)
+	suite("Profile", testProfile)
+	suite("SBOM", testSBOM)
+	suite("VersionScheme", testVersionScheme)
 	suite.Run(t)
 }
@@ -75,6 +75,12 @@ func (w EnvironmentWriter) Write(path string, environment map[string]string) err
 	for _, k := range keys {
 		w.logger.Body("Writing %s/%s", base, k)
 		f := filepath.Join(path, k)
+
+		// Files with unchanged contents are not rewritten so that normalized modification times are preserved
+		if b, err := ioutil.ReadFile(f); err == nil && string(b) == environment[k] {
+			continue
+		}
+
 		if err := ioutil.WriteFile(f, []byte(environment[k]), 0644); err != nil {
 			return fmt.Errorf("unable to write file %s: %w", f, err)
 		}
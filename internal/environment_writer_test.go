@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/paketo-buildpacks/libpak/bard"
@@ -66,6 +67,25 @@ func testEnvironmentWriter(t *testing.T, context spec.G, it spec.S) {
 		Expect(string(content)).To(Equal("other-content"))
 	})
 
+	it("does not rewrite unchanged files", func() {
+		Expect(writer.Write(path, map[string]string{"some-name": "some-content", "other-name": "other-content"})).
+			To(Succeed())
+
+		epoch := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+		for _, f := range []string{"some-name", "other-name"} {
+			Expect(os.Chtimes(filepath.Join(path, f), epoch, epoch)).To(Succeed())
+		}
+
+		Expect(writer.Write(path, map[string]string{"some-name": "some-content", "other-name": "changed-content"})).
+			To(Succeed())
+
+		info, err := os.Stat(filepath.Join(path, "some-name"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.ModTime().UTC()).To(Equal(epoch))
+
+		Expect(ioutil.ReadFile(filepath.Join(path, "other-name"))).To(Equal([]byte("changed-content")))
+	})
+
 	it("writes does not create a directory of the env map is empty", func() {
 		err := writer.Write(path, map[string]string{})
 		Expect(err).NotTo(HaveOccurred())
@@ -17,17 +17,133 @@
 package libpak
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/buildpacks/libcnb"
 	"github.com/heroku/color"
 	"github.com/mitchellh/mapstructure"
 	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-buildpacks/libpak/internal"
+	"github.com/paketo-buildpacks/libpak/sherpa"
 )
 
+// LayerTypes are the types of a layer.
+type LayerTypes struct {
+
+	// Build indicates that a layer should be used for builds.
+	Build bool
+
+	// Cache indicates that a layer should be cached.
+	Cache bool
+
+	// Launch indicates that a layer should be used for launch.
+	Launch bool
+}
+
+// NewLayerTypes creates a new instance from the flags of a layer.
+func NewLayerTypes(layer libcnb.Layer) LayerTypes {
+	return LayerTypes{Build: layer.Build, Cache: layer.Cache, Launch: layer.Launch}
+}
+
+// Apply sets the flags of a layer to the types.
+func (l LayerTypes) Apply(layer libcnb.Layer) libcnb.Layer {
+	layer.Build = l.Build
+	layer.Cache = l.Cache
+	layer.Launch = l.Launch
+	return layer
+}
+
+// IsEmpty indicates whether none of the types are set.
+func (l LayerTypes) IsEmpty() bool {
+	return l == LayerTypes{}
+}
+
+// LayerLimits are limits on the contents of a contributed layer.  A zero value means that there is no limit.
+type LayerLimits struct {
+
+	// Files is the maximum number of files in the layer.
+	Files int64
+
+	// Size is the maximum disk usage, in bytes, of the files in the layer.
+	Size int64
+}
+
+// LayerUsage is the measured contents of a contributed layer.
+type LayerUsage struct {
+
+	// Files is the number of distinct regular files in the layer.
+	Files int64
+
+	// Size is the disk usage, in bytes, of the files in the layer.
+	Size int64
+}
+
+// Exceeds returns a description of each of the limits that the usage exceeds.
+func (l LayerUsage) Exceeds(limits LayerLimits) []string {
+	var exceeded []string
+
+	if limits.Files > 0 && l.Files > limits.Files {
+		exceeded = append(exceeded, fmt.Sprintf("%d files exceeds limit of %d", l.Files, limits.Files))
+	}
+
+	if limits.Size > 0 && l.Size > limits.Size {
+		exceeded = append(exceeded, fmt.Sprintf("%s exceeds limit of %s", bard.FormatSize(l.Size), bard.FormatSize(limits.Size)))
+	}
+
+	return exceeded
+}
+
+// NewLayerUsage measures the number and disk usage of the regular files under path.  Disk usage is measured in
+// allocated blocks rather than apparent size, so sparse files count only the blocks they use, and files with multiple
+// hard links are counted once.  If the platform does not report allocated blocks, the apparent size is used.
+func NewLayerUsage(path string) (LayerUsage, error) {
+	var (
+		u       LayerUsage
+		counted = map[[2]uint64]bool{}
+	)
+
+	if err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			u.Files++
+			u.Size += info.Size()
+			return nil
+		}
+
+		inode := [2]uint64{uint64(st.Dev), uint64(st.Ino)}
+		if counted[inode] {
+			return nil
+		}
+		counted[inode] = true
+
+		u.Files++
+		u.Size += int64(st.Blocks) * 512
+
+		return nil
+	}); err != nil {
+		return LayerUsage{}, fmt.Errorf("unable to measure %s: %w", path, err)
+	}
+
+	return u, nil
+}
+
 // LayerContributor is a helper for implementing a libcnb.LayerContributor in order to get consistent logging and
 // avoidance.
 type LayerContributor struct {
@@ -35,6 +151,43 @@ type LayerContributor struct {
 	// ExpectedMetadata is the metadata to compare against any existing layer metadata.
 	ExpectedMetadata interface{}
 
+	// InputPaths are the paths of files and directories whose contents are inputs to the layer.  A fingerprint of their
+	// contents is stored in the layer metadata and a change in the fingerprint forces recontribution.  Paths that do
+	// not exist are ignored.
+	InputPaths []string
+
+	// ProfileScripts are the profile.d scripts of the layer, keyed by file name.  They are added to the layer on every
+	// contribution.  A change in their content updates the scripts of an existing layer without recontributing it.
+	ProfileScripts map[string]ProfileScript
+
+	// Reproducible indicates that the contents of the layer should be normalized after contribution.  The modification
+	// time of every entry is set to $SOURCE_DATE_EPOCH, or a fixed epoch if it is not set, and group and other write
+	// permissions are removed.  The environment and profile.d scripts of the layer are written before normalization so
+	// that they are normalized as well.  A digest of the layer, computed over its entries in lexical order, is stored in
+	// the layer metadata so that contributions can be compared.
+	Reproducible bool
+
+	// SoftLimits are limits on the contents of the contributed layer that log a warning if exceeded.
+	SoftLimits LayerLimits
+
+	// HardLimits are limits on the contents of the contributed layer that fail the contribution if exceeded.
+	HardLimits LayerLimits
+
+	// SchemaVersion is the version of the shape of ExpectedMetadata.  It is stored in the layer metadata and should be
+	// incremented whenever the shape changes.
+	SchemaVersion int
+
+	// Migrations are functions that migrate layer metadata from one schema version to the next, keyed by the version
+	// they migrate from.  Existing metadata with an older schema version is migrated before being compared with
+	// ExpectedMetadata.  If any migration between the existing and current schema versions is not registered, or the
+	// existing schema version is newer, the layer is invalidated and recontributed.
+	Migrations map[int]LayerMetadataMigration
+
+	// ExpectedTypes are the types of the layer.  If set, they are applied to both reused and contributed layers, and a
+	// change in types from an existing layer forces recontribution.  If not set, the flags set by the LayerFunc are
+	// used unchanged.
+	ExpectedTypes LayerTypes
+
 	// Logger is the logger to use.
 	Logger bard.Logger
 
@@ -51,44 +204,527 @@ func NewLayerContributor(name string, expectedMetadata interface{}) LayerContrib
 	}
 }
 
+// touchedLayers records the paths of the layers passed to LayerContributor.Contribute during a build.
+var touchedLayers = &layerTracker{paths: map[string]bool{}}
+
+type layerTracker struct {
+	mutex sync.Mutex
+	paths map[string]bool
+}
+
+func (l *layerTracker) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.paths = map[string]bool{}
+}
+
+func (l *layerTracker) touch(path string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.paths[filepath.Clean(path)] = true
+}
+
+func (l *layerTracker) touched(path string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.paths[filepath.Clean(path)]
+}
+
+// LayerMetadataMigration is a function that migrates layer metadata from one schema version to the next.
+type LayerMetadataMigration func(metadata map[string]interface{}) (map[string]interface{}, error)
+
 // LayerFunc is a callback function that is invoked when a layer needs to be contributed.
 type LayerFunc func() (libcnb.Layer, error)
 
-// Contribute is the function to call when implementing your libcnb.LayerContributor.
+// Contribute is the function to call when implementing your libcnb.LayerContributor.  The existing layer directory is
+// moved aside while f is called and restored if f returns an error or the contributed layer cannot be measured or
+// normalized.
 func (l *LayerContributor) Contribute(layer libcnb.Layer, f LayerFunc) (libcnb.Layer, error) {
-	expected := reflect.New(reflect.TypeOf(l.ExpectedMetadata))
-	expected.Elem().Set(reflect.ValueOf(l.ExpectedMetadata))
+	touchedLayers.touch(layer.Path)
+
+	fingerprint, err := l.fingerprint()
+	if err != nil {
+		return libcnb.Layer{}, err
+	}
+
+	scripts, err := l.renderProfileScripts()
+	if err != nil {
+		return libcnb.Layer{}, err
+	}
 
-	actual := reflect.New(reflect.TypeOf(l.ExpectedMetadata)).Interface()
-	if err := mapstructure.Decode(layer.Metadata, &actual); err != nil {
-		return libcnb.Layer{}, fmt.Errorf("unable to decode metadata into %s: %w", reflect.TypeOf(l.ExpectedMetadata), err)
+	reserved := map[string]interface{}{}
+	if fingerprint != "" {
+		reserved[inputFingerprintKey] = fingerprint
 	}
+	profile := l.profileFingerprints(scripts)
+
+	metadata, current, err := l.migrate(layer.Metadata)
+	if err != nil {
+		return libcnb.Layer{}, err
+	}
+
+	if current {
+		expected := reflect.New(reflect.TypeOf(l.ExpectedMetadata))
+		expected.Elem().Set(reflect.ValueOf(l.ExpectedMetadata))
+
+		actual := reflect.New(reflect.TypeOf(l.ExpectedMetadata)).Interface()
+		if err := mapstructure.Decode(metadata, &actual); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to decode metadata into %s: %w", reflect.TypeOf(l.ExpectedMetadata), err)
+		}
+
+		if reflect.DeepEqual(expected.Interface(), actual) && l.typesMatch(layer) &&
+			reflect.DeepEqual(reserved, l.comparableContributorMetadata(layer.Metadata)) {
+
+			l.Logger.Header("%s: %s cached layer", color.BlueString(l.Name), color.GreenString("Reusing"))
+
+			if metadata == nil {
+				metadata = map[string]interface{}{}
+			}
+			for _, k := range []string{filesKey, sizeKey, digestKey} {
+				if v, ok := l.contributorMetadata(layer.Metadata)[k]; ok {
+					reserved[k] = v
+				}
+			}
 
-	if reflect.DeepEqual(expected.Interface(), actual) {
-		l.Logger.Header("%s: %s cached layer", color.BlueString(l.Name), color.GreenString("Reusing"))
-		return layer, nil
+			if err := l.updateProfile(&layer, scripts, profile, reserved); err != nil {
+				return libcnb.Layer{}, err
+			}
+
+			layer.Metadata = metadata
+			l.setContributorMetadata(layer.Metadata, reserved)
+			return l.applyTypes(layer), nil
+		}
 	}
 
 	l.Logger.Header("%s: %s to layer", color.BlueString(l.Name), color.YellowString("Contributing"))
+	l.logCacheMiss(layer, reserved)
 
-	if err := os.RemoveAll(layer.Path); err != nil {
-		return libcnb.Layer{}, fmt.Errorf("unable to remove existing layer directory %s: %w", layer.Path, err)
+	backup, err := l.stage(layer)
+	if err != nil {
+		return libcnb.Layer{}, err
 	}
 
-	if err := os.MkdirAll(layer.Path, 0755); err != nil {
-		return libcnb.Layer{}, fmt.Errorf("unable to create layer directory %s: %w", layer.Path, err)
+	path := layer.Path
+	layer, err = f()
+	if err == nil {
+		var usage LayerUsage
+		if usage, err = l.measure(layer.Path); err == nil {
+			reserved[filesKey] = usage.Files
+			reserved[sizeKey] = usage.Size
+		}
+	}
+
+	if err == nil {
+		l.addProfile(&layer, scripts, profile, reserved)
+
+		if l.Reproducible {
+			var digest string
+			if digest, err = l.normalize(layer); err == nil {
+				reserved[digestKey] = digest
+			}
+		}
 	}
 
-	layer, err := f()
 	if err != nil {
+		if rErr := l.rollback(path, backup); rErr != nil {
+			return libcnb.Layer{}, fmt.Errorf("%w\n%s", err, rErr)
+		}
 		return libcnb.Layer{}, err
 	}
 
+	if backup != "" {
+		if err := os.RemoveAll(backup); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to remove previous layer directory %s: %w", backup, err)
+		}
+	}
+
 	if err := mapstructure.Decode(l.ExpectedMetadata, &layer.Metadata); err != nil {
 		return libcnb.Layer{}, fmt.Errorf("unable to encode metadata into %+v: %w", l.ExpectedMetadata, err)
 	}
 
-	return layer, nil
+	l.setContributorMetadata(layer.Metadata, reserved)
+
+	return l.applyTypes(layer), nil
+}
+
+// addProfile adds profile.d scripts to a layer and records their fingerprints.
+func (l LayerContributor) addProfile(layer *libcnb.Layer, scripts map[string]string, profile map[string]interface{},
+	reserved map[string]interface{}) {
+
+	if len(scripts) == 0 {
+		return
+	}
+
+	if layer.Profile == nil {
+		layer.Profile = libcnb.Profile{}
+	}
+	for name, script := range scripts {
+		layer.Profile[name] = script
+	}
+
+	reserved[profileScriptsKey] = profile
+}
+
+// updateProfile adds profile.d scripts to a reused layer.  If the scripts have changed, scripts that are no longer
+// declared are removed and, for reproducible layers, the layer is normalized again.
+func (l LayerContributor) updateProfile(layer *libcnb.Layer, scripts map[string]string, profile map[string]interface{},
+	reserved map[string]interface{}) error {
+
+	l.addProfile(layer, scripts, profile, reserved)
+
+	previous, _ := l.contributorMetadata(layer.Metadata)[profileScriptsKey].(map[string]interface{})
+	if reflect.DeepEqual(previous, profile) || (len(previous) == 0 && len(profile) == 0) {
+		return nil
+	}
+
+	l.Logger.Body("Updating profile.d scripts")
+
+	for name := range previous {
+		if _, ok := scripts[name]; ok {
+			continue
+		}
+
+		delete(layer.Profile, name)
+		file := filepath.Join(layer.Path, "profile.d", name)
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to remove profile.d script %s: %w", file, err)
+		}
+	}
+
+	if l.Reproducible {
+		digest, err := l.normalize(*layer)
+		if err != nil {
+			return err
+		}
+		reserved[digestKey] = digest
+	}
+
+	return nil
+}
+
+// measure measures the contents of a contributed layer and checks them against the limits.
+func (l LayerContributor) measure(path string) (LayerUsage, error) {
+	usage, err := NewLayerUsage(path)
+	if err != nil {
+		return LayerUsage{}, err
+	}
+
+	l.Logger.Body("%d files, %s", usage.Files, bard.FormatSize(usage.Size))
+
+	if exceeded := usage.Exceeds(l.HardLimits); len(exceeded) > 0 {
+		return LayerUsage{}, fmt.Errorf("layer %s exceeds hard limits: %s", l.Name, strings.Join(exceeded, ", "))
+	}
+
+	for _, e := range usage.Exceeds(l.SoftLimits) {
+		l.Logger.Body("%s %s", color.New(color.FgYellow, color.Bold).Sprint("Warning:"), e)
+	}
+
+	return usage, nil
+}
+
+// normalize writes the environment and profile.d scripts of a contributed layer into the layer directory, normalizes
+// the layer directory, and returns its digest.  The files are written before normalization, with the same contents
+// that are written after contribution, so that their modification times are normalized as well.
+func (l LayerContributor) normalize(layer libcnb.Layer) (string, error) {
+	w := internal.NewEnvironmentWriter(internal.WithEnvironmentWriterLogger(bard.NewLogger(ioutil.Discard)))
+	for name, environment := range map[string]map[string]string{
+		"env":        layer.SharedEnvironment,
+		"env.build":  layer.BuildEnvironment,
+		"env.launch": layer.LaunchEnvironment,
+		"profile.d":  layer.Profile,
+	} {
+		if err := w.Write(filepath.Join(layer.Path, name), environment); err != nil {
+			return "", fmt.Errorf("unable to write layer %s: %w", name, err)
+		}
+	}
+
+	epoch, err := sherpa.SourceDateEpoch()
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := sherpa.Normalize(layer.Path, epoch)
+	if err != nil {
+		return "", err
+	}
+
+	l.Logger.Debug("Normalized %d entries in %s", len(changes), layer.Path)
+	for _, c := range changes {
+		l.Logger.Debug("  %s", c)
+	}
+
+	digest, err := sherpa.Digest(layer.Path)
+	if err != nil {
+		return "", err
+	}
+	l.Logger.Debug("Layer digest: sha256:%s", digest)
+
+	return digest, nil
+}
+
+// stage moves any existing layer directory to a backup directory alongside it and creates an empty layer directory to
+// contribute into.  It returns the path of the backup directory, or "" if there was no existing layer directory.
+func (LayerContributor) stage(layer libcnb.Layer) (string, error) {
+	var backup string
+
+	if _, err := os.Stat(layer.Path); err == nil {
+		if backup, err = ioutil.TempDir(filepath.Dir(layer.Path), fmt.Sprintf(".%s-", filepath.Base(layer.Path))); err != nil {
+			return "", fmt.Errorf("unable to create backup directory for %s: %w", layer.Path, err)
+		}
+
+		if err := os.Remove(backup); err != nil {
+			return "", fmt.Errorf("unable to remove backup directory %s: %w", backup, err)
+		}
+
+		if err := os.Rename(layer.Path, backup); err != nil {
+			return "", fmt.Errorf("unable to move existing layer directory %s to %s: %w", layer.Path, backup, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("unable to stat %s: %w", layer.Path, err)
+	}
+
+	if err := os.MkdirAll(layer.Path, 0755); err != nil {
+		return "", fmt.Errorf("unable to create layer directory %s: %w", layer.Path, err)
+	}
+
+	return backup, nil
+}
+
+// rollback removes a partially contributed layer directory and restores the previous layer directory from its backup,
+// if there was one.
+func (LayerContributor) rollback(path string, backup string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("unable to remove partial layer directory %s: %w", path, err)
+	}
+
+	if backup == "" {
+		return nil
+	}
+
+	if err := os.Rename(backup, path); err != nil {
+		return fmt.Errorf("unable to restore layer directory %s from %s: %w", path, backup, err)
+	}
+
+	return nil
+}
+
+func (l LayerContributor) logCacheMiss(layer libcnb.Layer, reserved map[string]interface{}) {
+	if !l.Logger.IsDebugEnabled() {
+		return
+	}
+
+	diff, err := diffMetadata(l.ExpectedMetadata, l.withoutContributorMetadata(layer.Metadata))
+	if err != nil {
+		l.Logger.Debug("Unable to compare layer metadata: %s", err)
+		return
+	}
+
+	if !l.typesMatch(layer) {
+		diff = append(diff, fmt.Sprintf("types: %+v -> %+v", NewLayerTypes(layer), l.ExpectedTypes))
+	}
+
+	if v := l.schemaVersion(layer.Metadata); v != l.SchemaVersion {
+		diff = append(diff, fmt.Sprintf("schema version: %d -> %d", v, l.SchemaVersion))
+	}
+
+	r, err := diffMetadata(reserved, l.comparableContributorMetadata(layer.Metadata))
+	if err != nil {
+		l.Logger.Debug("Unable to compare layer metadata: %s", err)
+		return
+	}
+	for _, d := range r {
+		diff = append(diff, fmt.Sprintf("%s.%s", contributorMetadataKey, d))
+	}
+
+	l.Logger.Debug("Layer %s cache miss:", l.Name)
+	for _, d := range diff {
+		l.Logger.Debug("  %s", d)
+	}
+}
+
+const (
+	// contributorMetadataKey is the layer metadata key under which LayerContributor stores its own metadata, separate
+	// from ExpectedMetadata.
+	contributorMetadataKey = "libpak"
+
+	inputFingerprintKey = "input-fingerprint"
+
+	profileScriptsKey = "profile-scripts"
+
+	schemaVersionKey = "schema-version"
+
+	filesKey = "files"
+
+	sizeKey = "size"
+
+	digestKey = "digest"
+)
+
+func (LayerContributor) contributorMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if m, ok := metadata[contributorMetadataKey].(map[string]interface{}); ok {
+		return m
+	}
+
+	return map[string]interface{}{}
+}
+
+// comparableContributorMetadata returns the contributor metadata that must match for a layer to be reused.
+func (l LayerContributor) comparableContributorMetadata(metadata map[string]interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range l.contributorMetadata(metadata) {
+		if k != schemaVersionKey && k != filesKey && k != sizeKey && k != digestKey && k != profileScriptsKey {
+			m[k] = v
+		}
+	}
+
+	return m
+}
+
+func (l LayerContributor) setContributorMetadata(metadata map[string]interface{}, reserved map[string]interface{}) {
+	delete(metadata, contributorMetadataKey)
+
+	m := map[string]interface{}{}
+	for k, v := range reserved {
+		m[k] = v
+	}
+	if l.SchemaVersion > 0 {
+		m[schemaVersionKey] = l.SchemaVersion
+	}
+
+	if len(m) > 0 {
+		metadata[contributorMetadataKey] = m
+	}
+}
+
+func (l LayerContributor) schemaVersion(metadata map[string]interface{}) int {
+	switch v := l.contributorMetadata(metadata)[schemaVersionKey].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// migrate migrates existing layer metadata to the current schema version.  It returns the migrated metadata, without
+// contributor metadata, and whether it is at the current schema version.
+func (l LayerContributor) migrate(metadata map[string]interface{}) (map[string]interface{}, bool, error) {
+	m := l.withoutContributorMetadata(metadata)
+	version := l.schemaVersion(metadata)
+
+	if version == l.SchemaVersion {
+		return m, true, nil
+	}
+
+	if version > l.SchemaVersion || len(metadata) == 0 {
+		return m, false, nil
+	}
+
+	for v := version; v < l.SchemaVersion; v++ {
+		f, ok := l.Migrations[v]
+		if !ok {
+			l.Logger.Debug("No migration for %s layer metadata from schema version %d", l.Name, v)
+			return m, false, nil
+		}
+
+		var err error
+		if m, err = f(m); err != nil {
+			return nil, false, fmt.Errorf("unable to migrate %s layer metadata from schema version %d: %w", l.Name, v, err)
+		}
+	}
+
+	return m, true, nil
+}
+
+func (LayerContributor) withoutContributorMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if _, ok := metadata[contributorMetadataKey]; !ok {
+		return metadata
+	}
+
+	m := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		if k != contributorMetadataKey {
+			m[k] = v
+		}
+	}
+
+	return m
+}
+
+func (l LayerContributor) renderProfileScripts() (map[string]string, error) {
+	scripts := map[string]string{}
+
+	for name, p := range l.ProfileScripts {
+		s, err := p.Render()
+		if err != nil {
+			return nil, fmt.Errorf("unable to render profile.d script %s: %w", name, err)
+		}
+
+		scripts[name] = s
+	}
+
+	return scripts, nil
+}
+
+// profileFingerprints returns the fingerprint of each profile.d script, keyed by file name.
+func (LayerContributor) profileFingerprints(scripts map[string]string) map[string]interface{} {
+	fingerprints := map[string]interface{}{}
+
+	for name, script := range scripts {
+		s := sha256.Sum256([]byte(script))
+		fingerprints[name] = hex.EncodeToString(s[:])
+	}
+
+	return fingerprints
+}
+
+func (l LayerContributor) fingerprint() (string, error) {
+	if len(l.InputPaths) == 0 {
+		return "", nil
+	}
+
+	s := sha256.New()
+
+	for _, p := range l.InputPaths {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			continue
+		}
+
+		entries, err := sherpa.NewFileListing(p)
+		if err != nil {
+			return "", fmt.Errorf("unable to create file listing for %s: %w", p, err)
+		}
+
+		_, _ = fmt.Fprintf(s, "%s\n", p)
+		for _, e := range entries {
+			r, err := filepath.Rel(p, e.Path)
+			if err != nil {
+				return "", fmt.Errorf("unable to determine relative path of %s: %w", e.Path, err)
+			}
+
+			_, _ = fmt.Fprintf(s, "%s %s\n", r, e.SHA256)
+		}
+	}
+
+	return hex.EncodeToString(s.Sum(nil)), nil
+}
+
+func (l LayerContributor) applyTypes(layer libcnb.Layer) libcnb.Layer {
+	if l.ExpectedTypes.IsEmpty() {
+		return layer
+	}
+
+	return l.ExpectedTypes.Apply(layer)
+}
+
+func (l LayerContributor) typesMatch(layer libcnb.Layer) bool {
+	return l.ExpectedTypes.IsEmpty() || l.ExpectedTypes == NewLayerTypes(layer)
 }
 
 // DependencyLayerContributor is a helper for implementing a libcnb.LayerContributor for a BuildpackDependency in order
@@ -107,7 +743,7 @@ type DependencyLayerContributor struct {
 
 // NewDependencyLayerContributor creates a new instance and adds the dependency to the Buildpack Plan.
 func NewDependencyLayerContributor(dependency BuildpackDependency, cache DependencyCache, plan *libcnb.BuildpackPlan) DependencyLayerContributor {
-	plan.Entries = append(plan.Entries, libcnb.BuildpackPlanEntry{
+	entry := libcnb.BuildpackPlanEntry{
 		Name:    dependency.ID,
 		Version: dependency.Version,
 		Metadata: map[string]interface{}{
@@ -117,7 +753,33 @@ func NewDependencyLayerContributor(dependency BuildpackDependency, cache Depende
 			"stacks":   dependency.Stacks,
 			"licenses": dependency.Licenses,
 		},
-	})
+	}
+
+	if dependency.Arch != "" {
+		entry.Metadata["arch"] = dependency.Arch
+	}
+
+	if dependency.OS != "" {
+		entry.Metadata["os"] = dependency.OS
+	}
+
+	if len(dependency.Tags) > 0 {
+		entry.Metadata["tags"] = dependency.Tags
+	}
+
+	if dependency.PURL != "" {
+		entry.Metadata["purl"] = dependency.PURL
+	}
+
+	if len(dependency.CPEs) > 0 {
+		entry.Metadata["cpes"] = dependency.CPEs
+	}
+
+	if dependency.Overridden {
+		entry.Metadata["overridden"] = true
+	}
+
+	plan.Entries = append(plan.Entries, entry)
 
 	return DependencyLayerContributor{
 		Dependency:       dependency,
@@ -148,29 +810,72 @@ type HelperLayerContributor struct {
 	// Path is the path to the helper application.
 	Path string
 
+	// Names are the names of the exec.d entries that run the helper application.  If set, the helper application is
+	// installed once, as bin/<base name of Path>, and an exec.d entry is created for each name.
+	Names []string
+
+	// CopyExecD indicates that exec.d entries should be copies of the helper application rather than symlinks to it.
+	CopyExecD bool
+
+	// FingerprintHelper indicates that a fingerprint of the contents of the helper application is stored in the layer
+	// metadata so that a change in the helper application forces recontribution.
+	FingerprintHelper bool
+
 	// LayerContributor is the contained LayerContributor used for the actual contribution.
 	LayerContributor LayerContributor
 }
 
-// NewHelperLayerContributor creates a new instance and adds the helper to the Buildpack Plan.
-func NewHelperLayerContributor(path string, name string, info libcnb.BuildpackInfo, plan *libcnb.BuildpackPlan) HelperLayerContributor {
-	plan.Entries = append(plan.Entries, libcnb.BuildpackPlanEntry{
+// NewHelperLayerContributor creates a new instance and adds the helper to the Buildpack Plan.  If names are given, the
+// helper is installed with an exec.d entry for each name and the layer is marked as a launch layer.
+func NewHelperLayerContributor(path string, name string, info libcnb.BuildpackInfo, plan *libcnb.BuildpackPlan,
+	names ...string) HelperLayerContributor {
+
+	entry := libcnb.BuildpackPlanEntry{
 		Name:    filepath.Base(path),
 		Version: info.Version,
-	})
+	}
+
+	if len(names) > 0 {
+		entry.Metadata = map[string]interface{}{"names": names}
+	}
+
+	plan.Entries = append(plan.Entries, entry)
+
+	lc := NewLayerContributor(name, info)
+	if len(names) > 0 {
+		lc.ExpectedTypes = LayerTypes{Launch: true}
+	}
 
 	return HelperLayerContributor{
 		Path:             path,
-		LayerContributor: NewLayerContributor(name, info),
+		Names:            names,
+		LayerContributor: lc,
 	}
 }
 
 // DependencyLayerFunc is a callback function that is invoked when a helper needs to be contributed.
 type HelperLayerFunc func(artifact *os.File) (libcnb.Layer, error)
 
-// Contribute is the function to call whe implementing your libcnb.LayerContributor.
+// Contribute is the function to call whe implementing your libcnb.LayerContributor.  If Names are set, the helper
+// application and its exec.d entries are installed before f is called.  f may be nil if no further contribution is
+// required.
 func (h *HelperLayerContributor) Contribute(layer libcnb.Layer, f HelperLayerFunc) (libcnb.Layer, error) {
-	return h.LayerContributor.Contribute(layer, func() (libcnb.Layer, error) {
+	lc := h.LayerContributor
+	if h.FingerprintHelper {
+		lc.InputPaths = append(append([]string{}, lc.InputPaths...), h.Path)
+	}
+
+	return lc.Contribute(layer, func() (libcnb.Layer, error) {
+		if len(h.Names) > 0 {
+			if err := h.install(layer.Path); err != nil {
+				return libcnb.Layer{}, err
+			}
+		}
+
+		if f == nil {
+			return layer, nil
+		}
+
 		in, err := os.Open(h.Path)
 		if err != nil {
 			return libcnb.Layer{}, fmt.Errorf("unable to open %s: %w", h.Path, err)
@@ -179,3 +884,63 @@ func (h *HelperLayerContributor) Contribute(layer libcnb.Layer, f HelperLayerFun
 		return f(in)
 	})
 }
+
+func (h HelperLayerContributor) install(path string) error {
+	in, err := os.Open(h.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", h.Path, err)
+	}
+	defer in.Close()
+
+	helper := filepath.Join(path, "bin", filepath.Base(h.Path))
+	h.LayerContributor.Logger.Body("Installing %s", helper)
+	if err := sherpa.CopyFile(in, helper); err != nil {
+		return fmt.Errorf("unable to copy %s to %s: %w", h.Path, helper, err)
+	}
+
+	if err := os.Chmod(helper, 0755); err != nil {
+		return fmt.Errorf("unable to chmod %s: %w", helper, err)
+	}
+
+	execD := filepath.Join(path, "exec.d")
+	if err := os.MkdirAll(execD, 0755); err != nil {
+		return fmt.Errorf("unable to create %s: %w", execD, err)
+	}
+
+	for _, name := range h.Names {
+		file := filepath.Join(execD, name)
+
+		if h.CopyExecD {
+			h.LayerContributor.Logger.Body("Copying %s to exec.d/%s", filepath.Base(helper), name)
+			if err := h.copy(helper, file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		h.LayerContributor.Logger.Body("Linking exec.d/%s to %s", name, filepath.Base(helper))
+		if err := os.Symlink(filepath.Join("..", "bin", filepath.Base(helper)), file); err != nil {
+			return fmt.Errorf("unable to link %s to %s: %w", file, helper, err)
+		}
+	}
+
+	return nil
+}
+
+func (HelperLayerContributor) copy(source string, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", source, err)
+	}
+	defer in.Close()
+
+	if err := sherpa.CopyFile(in, destination); err != nil {
+		return fmt.Errorf("unable to copy %s to %s: %w", source, destination, err)
+	}
+
+	if err := os.Chmod(destination, 0755); err != nil {
+		return fmt.Errorf("unable to chmod %s: %w", destination, err)
+	}
+
+	return nil
+}
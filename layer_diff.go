@@ -0,0 +1,140 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mitchellh/mapstructure"
+)
+
+// redacted is the value logged in place of values that look like secrets.
+const redacted = "<redacted>"
+
+var secretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private[-_]?key|api[-_]?key|access[-_]?key)`)
+
+// diffMetadata returns a description of each key whose value differs between the expected and actual metadata.
+// Nested maps are flattened into dotted keys and values that look like secrets are redacted.
+func diffMetadata(expected interface{}, actual map[string]interface{}) ([]string, error) {
+	e, err := normalizeMetadata(expected)
+	if err != nil {
+		return nil, err
+	}
+
+	ef := map[string]interface{}{}
+	flattenMetadata("", e, ef)
+
+	af := map[string]interface{}{}
+	flattenMetadata("", actual, af)
+
+	keys := map[string]struct{}{}
+	for k := range ef {
+		keys[k] = struct{}{}
+	}
+	for k := range af {
+		keys[k] = struct{}{}
+	}
+
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diff []string
+	for _, k := range sorted {
+		ev, eok := ef[k]
+		av, aok := af[k]
+
+		switch {
+		case !aok:
+			diff = append(diff, fmt.Sprintf("%s: added %s", k, redactValue(k, ev)))
+		case !eok:
+			diff = append(diff, fmt.Sprintf("%s: removed %s", k, redactValue(k, av)))
+		case !reflect.DeepEqual(ev, av):
+			diff = append(diff, fmt.Sprintf("%s: %s -> %s", k, redactValue(k, av), redactValue(k, ev)))
+		}
+	}
+
+	return diff, nil
+}
+
+// normalizeMetadata converts metadata to the form it takes when read back from a layer's TOML file so that it can be
+// compared with stored metadata.  Errors do not include the metadata, as it may contain secrets.
+func normalizeMetadata(metadata interface{}) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if err := mapstructure.Decode(metadata, &m); err != nil {
+		return nil, fmt.Errorf("unable to encode metadata into %T: %w", metadata, err)
+	}
+
+	b := &bytes.Buffer{}
+	if err := toml.NewEncoder(b).Encode(m); err != nil {
+		return nil, fmt.Errorf("unable to encode metadata: %w", err)
+	}
+
+	n := map[string]interface{}{}
+	if _, err := toml.Decode(b.String(), &n); err != nil {
+		return nil, fmt.Errorf("unable to decode metadata: %w", err)
+	}
+
+	return n, nil
+}
+
+func flattenMetadata(prefix string, metadata map[string]interface{}, flat map[string]interface{}) {
+	for k, v := range metadata {
+		key := k
+		if prefix != "" {
+			key = fmt.Sprintf("%s.%s", prefix, k)
+		}
+
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			flattenMetadata(key, m, flat)
+			continue
+		}
+
+		flat[key] = v
+	}
+}
+
+func redactValue(key string, value interface{}) string {
+	if secretKeyPattern.MatchString(key) {
+		return redacted
+	}
+
+	return redactCredentials(fmt.Sprintf("%q", fmt.Sprint(value)))
+}
+
+var urlPattern = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.\-]*://[^\s"]+`)
+
+func redactCredentials(s string) string {
+	return urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.User == nil {
+			return raw
+		}
+
+		u.User = url.User(redacted)
+		return strings.Replace(u.String(), url.PathEscape(redacted), redacted, 1)
+	})
+}
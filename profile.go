@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ProfileOperation is an operation applied to an environment variable by a profile.d script.
+type ProfileOperation uint8
+
+const (
+	// ProfileExport exports a value for an environment variable, replacing any previous value.
+	ProfileExport ProfileOperation = iota
+
+	// ProfileDefault exports a value for an environment variable only if it is unset or empty.
+	ProfileDefault
+
+	// ProfilePrependPath prepends a value to a PATH-like environment variable, separated from any previous value by :.
+	ProfilePrependPath
+)
+
+// ProfileEntry is a single operation on an environment variable in a profile.d script.
+type ProfileEntry struct {
+
+	// Operation is the operation to apply.
+	Operation ProfileOperation
+
+	// Name is the name of the environment variable.
+	Name string
+
+	// Value is the value to apply.  Unless Expression is set, it is quoted so that it is never subject to expansion by
+	// the shell.
+	Value string
+
+	// Expression indicates that Value is a shell expression that is evaluated at launch.  It is written unquoted, so any
+	// quoting that the expression requires must be part of Value.
+	Expression bool
+}
+
+// ProfileScript is a declarative description of a profile.d script.
+type ProfileScript struct {
+
+	// Entries are the operations of the script, in order.
+	Entries []ProfileEntry
+}
+
+// Default adds an entry that exports a value for an environment variable only if it is unset or empty.
+func (p *ProfileScript) Default(name string, format string, a ...interface{}) {
+	p.Entries = append(p.Entries, ProfileEntry{Operation: ProfileDefault, Name: name, Value: fmt.Sprintf(format, a...)})
+}
+
+// DefaultExpression adds an entry that exports the result of a shell expression for an environment variable only if it
+// is unset or empty.
+func (p *ProfileScript) DefaultExpression(name string, expression string) {
+	p.Entries = append(p.Entries, ProfileEntry{Operation: ProfileDefault, Name: name, Value: expression, Expression: true})
+}
+
+// Export adds an entry that exports a value for an environment variable.
+func (p *ProfileScript) Export(name string, format string, a ...interface{}) {
+	p.Entries = append(p.Entries, ProfileEntry{Operation: ProfileExport, Name: name, Value: fmt.Sprintf(format, a...)})
+}
+
+// ExportExpression adds an entry that exports the result of a shell expression for an environment variable.
+func (p *ProfileScript) ExportExpression(name string, expression string) {
+	p.Entries = append(p.Entries, ProfileEntry{Operation: ProfileExport, Name: name, Value: expression, Expression: true})
+}
+
+// PrependPath adds an entry that prepends a value to a PATH-like environment variable.
+func (p *ProfileScript) PrependPath(name string, format string, a ...interface{}) {
+	p.Entries = append(p.Entries, ProfileEntry{Operation: ProfilePrependPath, Name: name, Value: fmt.Sprintf(format, a...)})
+}
+
+// PrependPathExpression adds an entry that prepends the result of a shell expression to a PATH-like environment
+// variable.
+func (p *ProfileScript) PrependPathExpression(name string, expression string) {
+	p.Entries = append(p.Entries, ProfileEntry{Operation: ProfilePrependPath, Name: name, Value: expression, Expression: true})
+}
+
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Render renders the script as POSIX shell.
+func (p ProfileScript) Render() (string, error) {
+	var s strings.Builder
+
+	for _, e := range p.Entries {
+		if !shellNamePattern.MatchString(e.Name) {
+			return "", fmt.Errorf("invalid environment variable name %q", e.Name)
+		}
+
+		v := e.Value
+		if !e.Expression {
+			v = ShellQuote(e.Value)
+		}
+
+		switch e.Operation {
+		case ProfileExport:
+			_, _ = fmt.Fprintf(&s, "export %s=%s\n", e.Name, v)
+		case ProfileDefault:
+			_, _ = fmt.Fprintf(&s, "if [ -z \"${%s:-}\" ]; then\n  export %s=%s\nfi\n", e.Name, e.Name, v)
+		case ProfilePrependPath:
+			_, _ = fmt.Fprintf(&s, "export %s=%s\"${%s:+:${%s}}\"\n", e.Name, v, e.Name, e.Name)
+		default:
+			return "", fmt.Errorf("unknown profile operation %d for %s", e.Operation, e.Name)
+		}
+	}
+
+	return s.String(), nil
+}
+
+// ShellQuote quotes a value so that it is interpreted literally by a POSIX shell.
+func ShellQuote(value string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", `'"'"'`))
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak_test
+
+import (
+	"os/exec"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/sclevine/spec"
+)
+
+func testProfile(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		p libpak.ProfileScript
+	)
+
+	it.Before(func() {
+		p = libpak.ProfileScript{}
+	})
+
+	it("renders script", func() {
+		p.Export("TEST_EXPORT", "test-value")
+		p.Default("TEST_DEFAULT", "test-value")
+		p.PrependPath("PATH", "test-path/bin")
+
+		Expect(p.Render()).To(Equal(`export TEST_EXPORT='test-value'
+if [ -z "${TEST_DEFAULT:-}" ]; then
+  export TEST_DEFAULT='test-value'
+fi
+export PATH='test-path/bin'"${PATH:+:${PATH}}"
+`))
+	})
+
+	it("quotes values", func() {
+		Expect(libpak.ShellQuote(`test value $HOME "quoted" 'single'`)).
+			To(Equal(`'test value $HOME "quoted" '"'"'single'"'"''`))
+	})
+
+	it("evaluates literal values", func() {
+		p.Export("TEST_EXPORT", `test value $HOME "quoted" 'single' \n`)
+		p.Default("TEST_DEFAULT", "test-default")
+		p.Default("TEST_SET", "test-default")
+		p.PrependPath("TEST_PATH", "test path/$bin")
+		p.PrependPath("TEST_EMPTY_PATH", "test-path")
+
+		s, err := p.Render()
+		Expect(err).NotTo(HaveOccurred())
+
+		cmd := exec.Command("sh", "-c", s+`printf '%s|%s|%s|%s|%s' "$TEST_EXPORT" "$TEST_DEFAULT" "$TEST_SET" "$TEST_PATH" "$TEST_EMPTY_PATH"`)
+		cmd.Env = []string{"TEST_SET=test-set", "TEST_PATH=test-existing"}
+
+		Expect(cmd.Output()).To(Equal([]byte(
+			`test value $HOME "quoted" 'single' \n|test-default|test-set|test path/$bin:test-existing|test-path`)))
+	})
+
+	it("evaluates expressions", func() {
+		p.ExportExpression("TEST_EXPORT", `"$TEST_HOME/test value"`)
+		p.DefaultExpression("TEST_DEFAULT", "$(printf test-default)")
+		p.PrependPathExpression("TEST_PATH", `"${TEST_HOME}/bin"`)
+
+		s, err := p.Render()
+		Expect(err).NotTo(HaveOccurred())
+
+		cmd := exec.Command("sh", "-c", s+`printf '%s|%s|%s' "$TEST_EXPORT" "$TEST_DEFAULT" "$TEST_PATH"`)
+		cmd.Env = []string{"TEST_HOME=test-home", "TEST_PATH=test-existing"}
+
+		Expect(cmd.Output()).To(Equal([]byte(`test-home/test value|test-default|test-home/bin:test-existing`)))
+	})
+
+	it("rejects invalid names", func() {
+		p.Export("TEST-EXPORT", "test-value")
+
+		_, err := p.Render()
+		Expect(err).To(MatchError(`invalid environment variable name "TEST-EXPORT"`))
+	})
+}
@@ -0,0 +1,409 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// CycloneDXFileName is the name of the file that a CycloneDX SBOM is written to.
+	CycloneDXFileName = "sbom.cdx.json"
+
+	// SPDXFileName is the name of the file that an SPDX SBOM is written to.
+	SPDXFileName = "sbom.spdx.json"
+)
+
+// SBOM is a software bill of materials describing the dependencies contributed during a build.
+type SBOM struct {
+
+	// Name is the name of the SBOM, typically the buildpack ID.
+	Name string
+
+	// Created is the time the SBOM was created.
+	Created time.Time
+
+	// Dependencies are the dependencies described by the SBOM.
+	Dependencies []BuildpackDependency
+}
+
+// NewSBOM creates a new SBOM describing each dependency entry in a buildpack plan.  Dependency entries are those added
+// by NewDependencyLayerContributor.
+func NewSBOM(name string, plan libcnb.BuildpackPlan) (SBOM, error) {
+	s := SBOM{Name: name, Created: time.Now().UTC()}
+
+	for _, e := range plan.Entries {
+		if _, ok := e.Metadata["sha256"]; !ok {
+			continue
+		}
+
+		d := BuildpackDependency{ID: e.Name, Version: e.Version}
+		if err := mapstructure.Decode(e.Metadata, &d); err != nil {
+			return SBOM{}, fmt.Errorf("unable to decode dependency %s %s: %w", e.Name, e.Version, err)
+		}
+		d.ID, d.Version = e.Name, e.Version
+
+		s.Dependencies = append(s.Dependencies, d)
+	}
+
+	return s, nil
+}
+
+// PackageURL returns the package URL of a dependency.  If the dependency does not declare one, a generic package URL
+// is derived from its ID, version, URI, and SHA256.
+func PackageURL(dependency BuildpackDependency) string {
+	if dependency.PURL != "" {
+		return dependency.PURL
+	}
+
+	q := url.Values{}
+	if dependency.URI != "" {
+		q.Set("download_url", dependency.URI)
+	}
+	if dependency.SHA256 != "" {
+		q.Set("checksum", fmt.Sprintf("sha256:%s", dependency.SHA256))
+	}
+
+	s := fmt.Sprintf("pkg:generic/%s@%s", url.PathEscape(dependency.ID), url.PathEscape(dependency.Version))
+	if len(q) > 0 {
+		s = fmt.Sprintf("%s?%s", s, q.Encode())
+	}
+
+	return s
+}
+
+// CycloneDX encodes the SBOM as a CycloneDX 1.2 JSON document.
+func (s SBOM) CycloneDX() ([]byte, error) {
+	type hash struct {
+		Algorithm string `json:"alg"`
+		Content   string `json:"content"`
+	}
+
+	type license struct {
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+		URL  string `json:"url,omitempty"`
+	}
+
+	type licenseChoice struct {
+		License    *license `json:"license,omitempty"`
+		Expression string   `json:"expression,omitempty"`
+	}
+
+	type reference struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	}
+
+	type component struct {
+		Type       string          `json:"type"`
+		BOMRef     string          `json:"bom-ref"`
+		Name       string          `json:"name"`
+		Version    string          `json:"version"`
+		Hashes     []hash          `json:"hashes,omitempty"`
+		Licenses   []licenseChoice `json:"licenses,omitempty"`
+		CPE        string          `json:"cpe,omitempty"`
+		PURL       string          `json:"purl"`
+		References []reference     `json:"externalReferences,omitempty"`
+	}
+
+	type metadata struct {
+		Timestamp string `json:"timestamp"`
+		Component struct {
+			Type    string `json:"type"`
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"component"`
+	}
+
+	bom := struct {
+		Format      string      `json:"bomFormat"`
+		SpecVersion string      `json:"specVersion"`
+		Version     int         `json:"version"`
+		Metadata    metadata    `json:"metadata"`
+		Components  []component `json:"components"`
+	}{
+		Format:      "CycloneDX",
+		SpecVersion: "1.2",
+		Version:     1,
+		Components:  []component{},
+	}
+
+	bom.Metadata.Timestamp = s.Created.UTC().Format(time.RFC3339)
+	bom.Metadata.Component.Type = "application"
+	bom.Metadata.Component.Name = s.Name
+
+	for _, d := range s.Dependencies {
+		c := component{
+			Type:    "library",
+			BOMRef:  PackageURL(d),
+			Name:    d.Name,
+			Version: d.Version,
+			PURL:    PackageURL(d),
+		}
+
+		if c.Name == "" {
+			c.Name = d.ID
+		}
+
+		if d.SHA256 != "" {
+			c.Hashes = append(c.Hashes, hash{Algorithm: "SHA-256", Content: d.SHA256})
+		}
+
+		for _, l := range d.Licenses {
+			switch {
+			case strings.Contains(strings.TrimSpace(l.Type), " "):
+				c.Licenses = append(c.Licenses, licenseChoice{Expression: l.Type})
+			case l.Type != "":
+				c.Licenses = append(c.Licenses, licenseChoice{License: &license{ID: l.Type, URL: l.URI}})
+			case l.URI != "":
+				c.Licenses = append(c.Licenses, licenseChoice{License: &license{Name: l.URI, URL: l.URI}})
+			}
+		}
+
+		if len(d.CPEs) > 0 {
+			c.CPE = d.CPEs[0]
+		}
+
+		if d.URI != "" {
+			c.References = append(c.References, reference{Type: "distribution", URL: d.URI})
+		}
+
+		bom.Components = append(bom.Components, c)
+	}
+
+	b, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode CycloneDX SBOM: %w", err)
+	}
+
+	return b, nil
+}
+
+var spdxIDPattern = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+// SPDX encodes the SBOM as an SPDX 2.2 JSON document.
+func (s SBOM) SPDX() ([]byte, error) {
+	type checksum struct {
+		Algorithm string `json:"algorithm"`
+		Value     string `json:"checksumValue"`
+	}
+
+	type reference struct {
+		Category string `json:"referenceCategory"`
+		Type     string `json:"referenceType"`
+		Locator  string `json:"referenceLocator"`
+	}
+
+	type pkg struct {
+		SPDXID           string      `json:"SPDXID"`
+		Name             string      `json:"name"`
+		Version          string      `json:"versionInfo"`
+		DownloadLocation string      `json:"downloadLocation"`
+		FilesAnalyzed    bool        `json:"filesAnalyzed"`
+		Checksums        []checksum  `json:"checksums,omitempty"`
+		LicenseConcluded string      `json:"licenseConcluded"`
+		LicenseDeclared  string      `json:"licenseDeclared"`
+		Copyright        string      `json:"copyrightText"`
+		References       []reference `json:"externalRefs"`
+	}
+
+	type creationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+
+	type relationship struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	}
+
+	doc := struct {
+		SPDXVersion   string         `json:"spdxVersion"`
+		DataLicense   string         `json:"dataLicense"`
+		SPDXID        string         `json:"SPDXID"`
+		Name          string         `json:"name"`
+		Namespace     string         `json:"documentNamespace"`
+		CreationInfo  creationInfo   `json:"creationInfo"`
+		Packages      []pkg          `json:"packages"`
+		Relationships []relationship `json:"relationships"`
+	}{
+		SPDXVersion: "SPDX-2.2",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        s.Name,
+		CreationInfo: creationInfo{
+			Created:  s.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: libpak"},
+		},
+		Packages:      []pkg{},
+		Relationships: []relationship{},
+	}
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n", s.Name)
+
+	for i, d := range s.Dependencies {
+		p := pkg{
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%s-%d", spdxIDPattern.ReplaceAllString(d.ID, "-"), i+1),
+			Name:             d.Name,
+			Version:          d.Version,
+			DownloadLocation: d.URI,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			Copyright:        "NOASSERTION",
+			References: []reference{
+				{Category: "PACKAGE-MANAGER", Type: "purl", Locator: PackageURL(d)},
+			},
+		}
+
+		if p.Name == "" {
+			p.Name = d.ID
+		}
+
+		if p.DownloadLocation == "" {
+			p.DownloadLocation = "NOASSERTION"
+		}
+
+		if d.SHA256 != "" {
+			p.Checksums = append(p.Checksums, checksum{Algorithm: "SHA256", Value: d.SHA256})
+		}
+
+		var licenses []string
+		for _, l := range d.Licenses {
+			if l.Type == "" {
+				continue
+			}
+
+			if strings.Contains(strings.TrimSpace(l.Type), " ") {
+				licenses = append(licenses, fmt.Sprintf("(%s)", l.Type))
+			} else {
+				licenses = append(licenses, l.Type)
+			}
+		}
+		if len(licenses) > 0 {
+			p.LicenseDeclared = strings.Join(licenses, " AND ")
+		}
+
+		for _, c := range d.CPEs {
+			p.References = append(p.References, reference{Category: "SECURITY", Type: "cpe23Type", Locator: c})
+		}
+
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships,
+			relationship{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: p.SPDXID})
+
+		_, _ = fmt.Fprintf(h, "%s %s %s\n", d.ID, d.Version, d.SHA256)
+	}
+
+	doc.Namespace = fmt.Sprintf("https://paketo.io/spdx/%s-%s",
+		spdxIDPattern.ReplaceAllString(s.Name, "-"), hex.EncodeToString(h.Sum(nil)))
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode SPDX SBOM: %w", err)
+	}
+
+	return b, nil
+}
+
+// SBOMLayerContributor is a helper for contributing a launch layer containing CycloneDX and SPDX SBOMs for the
+// dependencies in a buildpack plan.
+type SBOMLayerContributor struct {
+
+	// LayerContributor is the contained LayerContributor used for the actual contribution.
+	LayerContributor LayerContributor
+
+	// ID is the name of the SBOM, typically the buildpack ID.
+	ID string
+
+	// Plan is the buildpack plan whose dependencies are described.  It is read when the layer is contributed so that
+	// dependencies added to the plan after the contributor is created are included.
+	Plan *libcnb.BuildpackPlan
+}
+
+// NewSBOMLayerContributor creates a new instance.  The layer is marked as a launch layer.
+func NewSBOMLayerContributor(id string, plan *libcnb.BuildpackPlan) SBOMLayerContributor {
+	lc := NewLayerContributor("Software Bill of Materials", nil)
+	lc.ExpectedTypes = LayerTypes{Launch: true}
+
+	return SBOMLayerContributor{
+		LayerContributor: lc,
+		ID:               id,
+		Plan:             plan,
+	}
+}
+
+// Contribute is the function to call when implementing your libcnb.LayerContributor.
+func (s SBOMLayerContributor) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
+	sbom, err := NewSBOM(s.ID, *s.Plan)
+	if err != nil {
+		return libcnb.Layer{}, err
+	}
+
+	b, err := json.Marshal(sbom.Dependencies)
+	if err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to encode dependencies: %w", err)
+	}
+	h := sha256.Sum256(b)
+
+	s.LayerContributor.ExpectedMetadata = map[string]interface{}{
+		"id":           s.ID,
+		"dependencies": hex.EncodeToString(h[:]),
+	}
+
+	return s.LayerContributor.Contribute(layer, func() (libcnb.Layer, error) {
+		for _, d := range []struct {
+			file   string
+			encode func() ([]byte, error)
+		}{
+			{CycloneDXFileName, sbom.CycloneDX},
+			{SPDXFileName, sbom.SPDX},
+		} {
+			b, err := d.encode()
+			if err != nil {
+				return libcnb.Layer{}, err
+			}
+
+			file := filepath.Join(layer.Path, d.file)
+			s.LayerContributor.Logger.Body("Writing %s", file)
+			if err := ioutil.WriteFile(file, b, 0644); err != nil {
+				return libcnb.Layer{}, fmt.Errorf("unable to write %s: %w", file, err)
+			}
+		}
+
+		return layer, nil
+	})
+}
+
+// Name returns the name of the layer.
+func (SBOMLayerContributor) Name() string {
+	return "sbom"
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/sclevine/spec"
+)
+
+func testSBOM(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dependency libpak.BuildpackDependency
+		plan       libcnb.BuildpackPlan
+	)
+
+	it.Before(func() {
+		dependency = libpak.BuildpackDependency{
+			ID:      "test-id",
+			Name:    "test-name",
+			Version: "1.1.1",
+			URI:     "https://localhost/test-uri",
+			SHA256:  "test-sha256",
+			Stacks:  []string{"test-stack"},
+			Licenses: []libpak.BuildpackDependencyLicense{
+				{Type: "Apache-2.0", URI: "https://localhost/test-license"},
+				{Type: "GPL-2.0 WITH Classpath-exception-2.0"},
+			},
+			CPEs: []string{"cpe:2.3:a:test:test-name:1.1.1:*:*:*:*:*:*:*"},
+		}
+
+		plan = libcnb.BuildpackPlan{}
+		plan.Entries = append(plan.Entries, libcnb.BuildpackPlanEntry{Name: "test-other"})
+		libpak.NewDependencyLayerContributor(dependency, libpak.DependencyCache{}, &plan)
+	})
+
+	it("creates SBOM from plan", func() {
+		s, err := libpak.NewSBOM("test-buildpack", plan)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(s.Name).To(Equal("test-buildpack"))
+		Expect(s.Dependencies).To(Equal([]libpak.BuildpackDependency{dependency}))
+	})
+
+	it("creates SBOM from decoded plan", func() {
+		s, err := libpak.NewSBOM("test-buildpack", libcnb.BuildpackPlan{Entries: []libcnb.BuildpackPlanEntry{
+			{
+				Name:    "test-id",
+				Version: "1.1.1",
+				Metadata: map[string]interface{}{
+					"name":     "test-name",
+					"uri":      "https://localhost/test-uri",
+					"sha256":   "test-sha256",
+					"stacks":   []interface{}{"test-stack"},
+					"licenses": []map[string]interface{}{{"type": "Apache-2.0"}},
+					"purl":     "pkg:generic/test-id@1.1.1",
+					"cpes":     []interface{}{"test-cpe"},
+				},
+			},
+		}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(s.Dependencies).To(Equal([]libpak.BuildpackDependency{
+			{
+				ID:       "test-id",
+				Name:     "test-name",
+				Version:  "1.1.1",
+				URI:      "https://localhost/test-uri",
+				SHA256:   "test-sha256",
+				Stacks:   []string{"test-stack"},
+				Licenses: []libpak.BuildpackDependencyLicense{{Type: "Apache-2.0"}},
+				PURL:     "pkg:generic/test-id@1.1.1",
+				CPEs:     []string{"test-cpe"},
+			},
+		}))
+	})
+
+	it("derives package URL", func() {
+		Expect(libpak.PackageURL(dependency)).
+			To(Equal("pkg:generic/test-id@1.1.1?checksum=sha256%3Atest-sha256&download_url=https%3A%2F%2Flocalhost%2Ftest-uri"))
+
+		dependency.PURL = "pkg:maven/test/test-id@1.1.1"
+		Expect(libpak.PackageURL(dependency)).To(Equal("pkg:maven/test/test-id@1.1.1"))
+	})
+
+	context("encoding", func() {
+		var (
+			s libpak.SBOM
+		)
+
+		it.Before(func() {
+			s = libpak.SBOM{
+				Name:         "test-buildpack",
+				Created:      time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+				Dependencies: []libpak.BuildpackDependency{dependency},
+			}
+		})
+
+		it("encodes CycloneDX", func() {
+			b, err := s.CycloneDX()
+			Expect(err).NotTo(HaveOccurred())
+
+			var d map[string]interface{}
+			Expect(json.Unmarshal(b, &d)).To(Succeed())
+
+			Expect(d).To(HaveKeyWithValue("bomFormat", "CycloneDX"))
+			Expect(d).To(HaveKeyWithValue("specVersion", "1.2"))
+			Expect(d["metadata"]).To(HaveKeyWithValue("timestamp", "2020-05-01T00:00:00Z"))
+			Expect(d["components"]).To(Equal([]interface{}{
+				map[string]interface{}{
+					"type":    "library",
+					"bom-ref": libpak.PackageURL(dependency),
+					"name":    "test-name",
+					"version": "1.1.1",
+					"hashes":  []interface{}{map[string]interface{}{"alg": "SHA-256", "content": "test-sha256"}},
+					"licenses": []interface{}{
+						map[string]interface{}{"license": map[string]interface{}{"id": "Apache-2.0", "url": "https://localhost/test-license"}},
+						map[string]interface{}{"expression": "GPL-2.0 WITH Classpath-exception-2.0"},
+					},
+					"cpe":  "cpe:2.3:a:test:test-name:1.1.1:*:*:*:*:*:*:*",
+					"purl": libpak.PackageURL(dependency),
+					"externalReferences": []interface{}{
+						map[string]interface{}{"type": "distribution", "url": "https://localhost/test-uri"},
+					},
+				},
+			}))
+		})
+
+		it("encodes SPDX", func() {
+			b, err := s.SPDX()
+			Expect(err).NotTo(HaveOccurred())
+
+			var d map[string]interface{}
+			Expect(json.Unmarshal(b, &d)).To(Succeed())
+
+			Expect(d).To(HaveKeyWithValue("spdxVersion", "SPDX-2.2"))
+			Expect(d).To(HaveKeyWithValue("documentNamespace", MatchRegexp(`^https://paketo.io/spdx/test-buildpack-[0-9a-f]{64}$`)))
+			Expect(d["creationInfo"]).To(HaveKeyWithValue("created", "2020-05-01T00:00:00Z"))
+			Expect(d["packages"]).To(Equal([]interface{}{
+				map[string]interface{}{
+					"SPDXID":           "SPDXRef-Package-test-id-1",
+					"name":             "test-name",
+					"versionInfo":      "1.1.1",
+					"downloadLocation": "https://localhost/test-uri",
+					"filesAnalyzed":    false,
+					"checksums":        []interface{}{map[string]interface{}{"algorithm": "SHA256", "checksumValue": "test-sha256"}},
+					"licenseConcluded": "NOASSERTION",
+					"licenseDeclared":  "Apache-2.0 AND (GPL-2.0 WITH Classpath-exception-2.0)",
+					"copyrightText":    "NOASSERTION",
+					"externalRefs": []interface{}{
+						map[string]interface{}{
+							"referenceCategory": "PACKAGE-MANAGER",
+							"referenceType":     "purl",
+							"referenceLocator":  libpak.PackageURL(dependency),
+						},
+						map[string]interface{}{
+							"referenceCategory": "SECURITY",
+							"referenceType":     "cpe23Type",
+							"referenceLocator":  "cpe:2.3:a:test:test-name:1.1.1:*:*:*:*:*:*:*",
+						},
+					},
+				},
+			}))
+			Expect(d["relationships"]).To(Equal([]interface{}{
+				map[string]interface{}{
+					"spdxElementId":      "SPDXRef-DOCUMENT",
+					"relationshipType":   "DESCRIBES",
+					"relatedSpdxElement": "SPDXRef-Package-test-id-1",
+				},
+			}))
+		})
+	})
+
+	context("SBOMLayerContributor", func() {
+		var (
+			layer libcnb.Layer
+			path  string
+		)
+
+		it.Before(func() {
+			var err error
+
+			path, err = ioutil.TempDir("", "sbom")
+			Expect(err).NotTo(HaveOccurred())
+
+			layer.Metadata = map[string]interface{}{}
+			layer.Path = path
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(path)).To(Succeed())
+		})
+
+		it("contributes SBOMs", func() {
+			s := libpak.NewSBOMLayerContributor("test-buildpack", &plan)
+			Expect(s.Name()).To(Equal("sbom"))
+
+			layer, err := s.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(layer.Launch).To(BeTrue())
+			Expect(filepath.Join(path, libpak.CycloneDXFileName)).To(BeARegularFile())
+			Expect(filepath.Join(path, libpak.SPDXFileName)).To(BeARegularFile())
+		})
+
+		it("includes dependencies added after creation", func() {
+			s := libpak.NewSBOMLayerContributor("test-buildpack", &plan)
+
+			dependency.ID = "test-id-2"
+			libpak.NewDependencyLayerContributor(dependency, libpak.DependencyCache{}, &plan)
+
+			_, err := s.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			b, err := ioutil.ReadFile(filepath.Join(path, libpak.SPDXFileName))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(ContainSubstring("SPDXRef-Package-test-id-2-2"))
+		})
+	})
+}
@@ -49,7 +49,7 @@ type result struct {
 	value FileEntry
 }
 
-// NewFileListing generates a listing of all entries under root.
+// NewFileListing generates a listing of all entries under root.  If root is a file, the listing contains only root.
 func NewFileListing(root string) ([]FileEntry, error) {
 	ch := make(chan result)
 	var wg sync.WaitGroup
@@ -59,7 +59,7 @@ func NewFileListing(root string) ([]FileEntry, error) {
 			return err
 		}
 
-		if path == root {
+		if path == root && info.IsDir() {
 			return nil
 		}
 
@@ -55,4 +55,14 @@ func testFileListing(t *testing.T, context spec.G, it spec.S) {
 
 		Expect(e).To(HaveLen(3))
 	})
+
+	it("create listing of file", func() {
+		Expect(ioutil.WriteFile(filepath.Join(path, "alpha.txt"), []byte{}, 0644)).To(Succeed())
+
+		e, err := sherpa.NewFileListing(filepath.Join(path, "alpha.txt"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(e).To(HaveLen(1))
+		Expect(e[0].Path).To(Equal(filepath.Join(path, "alpha.txt")))
+	})
 }
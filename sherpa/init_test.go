@@ -27,6 +27,7 @@ func TestUnit(t *testing.T) {
 	suite := spec.New("libpak/sherpa", spec.Report(report.Terminal{}))
 	suite("CopyFile", testCopyFile)
 	suite("FileListing", testFileListing)
+	suite("Normalize", testNormalize)
 	suite("ResolveVersion", testResolveVersion)
 	suite("Sherpa", testSherpa)
 	suite.Run(t)
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sherpa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSourceDateEpoch is the modification time used for normalized files when $SOURCE_DATE_EPOCH is not set.  It
+// matches the time used by the lifecycle when exporting layers.
+var DefaultSourceDateEpoch = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+// SourceDateEpoch returns the time described by $SOURCE_DATE_EPOCH, or DefaultSourceDateEpoch if it is not set.
+func SourceDateEpoch() (time.Time, error) {
+	s, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || s == "" {
+		return DefaultSourceDateEpoch, nil
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse $SOURCE_DATE_EPOCH %s: %w", s, err)
+	}
+
+	return time.Unix(i, 0).UTC(), nil
+}
+
+// Normalize sets the modification time of root and every entry under it to mtime and removes group and other write
+// permissions.  Symbolic links are not followed or modified.  Entries are visited in lexical order and a description
+// of each change is returned in that order, with paths relative to root.
+func Normalize(root string, mtime time.Time) ([]string, error) {
+	var (
+		changes []string
+		dirs    []string
+	)
+
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("unable to determine relative path of %s: %w", path, err)
+		}
+
+		var c []string
+
+		if mode := info.Mode().Perm(); mode&0022 != 0 {
+			if err := os.Chmod(path, info.Mode()&^0022); err != nil {
+				return fmt.Errorf("unable to chmod %s: %w", path, err)
+			}
+
+			c = append(c, fmt.Sprintf("mode %04o -> %04o", mode, mode&^0022))
+		}
+
+		if !info.ModTime().Equal(mtime) {
+			c = append(c, fmt.Sprintf("mtime %s -> %s", info.ModTime().UTC().Format(time.RFC3339), mtime.UTC().Format(time.RFC3339)))
+		}
+
+		if len(c) > 0 {
+			changes = append(changes, fmt.Sprintf("%s: %s", rel, strings.Join(c, ", ")))
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			return fmt.Errorf("unable to set modification time of %s: %w", path, err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to normalize %s: %w", root, err)
+	}
+
+	// Directories are updated last, deepest first, so that changes to their contents do not alter their times
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i], mtime, mtime); err != nil {
+			return nil, fmt.Errorf("unable to set modification time of %s: %w", dirs[i], err)
+		}
+	}
+
+	return changes, nil
+}
+
+// Digest returns the SHA256 digest of root and every entry under it.  Entries are visited in lexical order and the
+// digest covers the relative path, mode, and modification time of each entry, and the contents of each file or the
+// target of each symbolic link, so that it does not depend on the order of entries on disk.
+func Digest(root string) (string, error) {
+	s := sha256.New()
+
+	if err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("unable to determine relative path of %s: %w", path, err)
+		}
+
+		_, _ = fmt.Fprintf(s, "%s\x00%s\x00%d\x00", filepath.ToSlash(rel), info.Mode(), info.ModTime().Unix())
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("unable to read link %s: %w", path, err)
+			}
+			_, _ = fmt.Fprintf(s, "%s\x00", target)
+		case info.Mode().IsRegular():
+			in, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("unable to open file %s: %w", path, err)
+			}
+			defer in.Close()
+
+			if _, err := io.Copy(s, in); err != nil {
+				return fmt.Errorf("unable to hash file %s: %w", path, err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("unable to digest %s: %w", root, err)
+	}
+
+	return hex.EncodeToString(s.Sum(nil)), nil
+}
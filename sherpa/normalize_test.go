@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sherpa_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak/sherpa"
+	"github.com/sclevine/spec"
+)
+
+func testNormalize(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		epoch = time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+		path  string
+	)
+
+	it.Before(func() {
+		var err error
+
+		path, err = ioutil.TempDir("", "normalize")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(path)).To(Succeed())
+	})
+
+	context("SourceDateEpoch", func() {
+
+		it.After(func() {
+			Expect(os.Unsetenv("SOURCE_DATE_EPOCH")).To(Succeed())
+		})
+
+		it("returns default", func() {
+			Expect(sherpa.SourceDateEpoch()).To(Equal(sherpa.DefaultSourceDateEpoch))
+		})
+
+		it("returns $SOURCE_DATE_EPOCH", func() {
+			Expect(os.Setenv("SOURCE_DATE_EPOCH", "1588291200")).To(Succeed())
+
+			Expect(sherpa.SourceDateEpoch()).To(Equal(epoch))
+		})
+
+		it("returns error for invalid $SOURCE_DATE_EPOCH", func() {
+			Expect(os.Setenv("SOURCE_DATE_EPOCH", "test-value")).To(Succeed())
+
+			_, err := sherpa.SourceDateEpoch()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	it("normalizes modification times and permissions", func() {
+		Expect(os.MkdirAll(filepath.Join(path, "bravo"), 0777)).To(Succeed())
+		Expect(os.Chmod(filepath.Join(path, "bravo"), 0777)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(path, "alpha.txt"), []byte{}, 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(path, "bravo", "charlie.txt"), []byte{}, 0666)).To(Succeed())
+		Expect(os.Chmod(filepath.Join(path, "bravo", "charlie.txt"), 0666)).To(Succeed())
+		Expect(os.Symlink("alpha.txt", filepath.Join(path, "delta.txt"))).To(Succeed())
+
+		changes, err := sherpa.Normalize(path, epoch)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(changes).To(HaveLen(4))
+		Expect(changes[1]).To(HavePrefix("alpha.txt: mtime "))
+		Expect(changes[2]).To(HavePrefix("bravo: mode 0777 -> 0755, mtime "))
+		Expect(changes[3]).To(HavePrefix("bravo/charlie.txt: mode 0666 -> 0644, mtime "))
+
+		for _, p := range []string{".", "alpha.txt", "bravo", "bravo/charlie.txt"} {
+			info, err := os.Stat(filepath.Join(path, p))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ModTime().UTC()).To(Equal(epoch))
+			Expect(info.Mode().Perm() & 0022).To(BeZero())
+		}
+
+		changes, err = sherpa.Normalize(path, epoch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	context("Digest", func() {
+		var (
+			other string
+		)
+
+		it.Before(func() {
+			var err error
+
+			other, err = ioutil.TempDir("", "normalize-other")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(other)).To(Succeed())
+		})
+
+		it("does not depend on creation order", func() {
+			Expect(ioutil.WriteFile(filepath.Join(path, "alpha.txt"), []byte("test-alpha"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(path, "bravo.txt"), []byte("test-bravo"), 0644)).To(Succeed())
+			Expect(os.Symlink("alpha.txt", filepath.Join(path, "charlie.txt"))).To(Succeed())
+
+			Expect(os.Symlink("alpha.txt", filepath.Join(other, "charlie.txt"))).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(other, "bravo.txt"), []byte("test-bravo"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(other, "alpha.txt"), []byte("test-alpha"), 0644)).To(Succeed())
+
+			_, err := sherpa.Normalize(path, epoch)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = sherpa.Normalize(other, epoch)
+			Expect(err).NotTo(HaveOccurred())
+
+			a, err := sherpa.Digest(path)
+			Expect(err).NotTo(HaveOccurred())
+			b, err := sherpa.Digest(other)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a).To(Equal(b))
+		})
+
+		it("changes with contents", func() {
+			Expect(ioutil.WriteFile(filepath.Join(path, "alpha.txt"), []byte("test-alpha"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(other, "alpha.txt"), []byte("test-bravo"), 0644)).To(Succeed())
+
+			_, err := sherpa.Normalize(path, epoch)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = sherpa.Normalize(other, epoch)
+			Expect(err).NotTo(HaveOccurred())
+
+			a, err := sherpa.Digest(path)
+			Expect(err).NotTo(HaveOccurred())
+			b, err := sherpa.Digest(other)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a).NotTo(Equal(b))
+		})
+	})
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Version is a parsed dependency version.
+type Version interface {
+
+	// Compare returns -1, 0, or 1 if this version is less than, equal to, or greater than another version.  It panics if
+	// the other version was not parsed by the same kind of VersionScheme.
+	Compare(other Version) int
+
+	// Major returns the major portion of the version.
+	Major() uint64
+
+	// Minor returns the minor portion of the version.
+	Minor() uint64
+
+	// Prerelease returns the prerelease portion of the version, or "" if the version is not a prerelease.
+	Prerelease() string
+
+	// Release returns the version with its prerelease portion removed.  Its String is the original representation with
+	// the prerelease portion removed.
+	Release() Version
+
+	// String returns the original representation of the version.
+	String() string
+}
+
+// VersionConstraint is a parsed constraint that versions can be checked against.
+type VersionConstraint interface {
+
+	// Check indicates whether a version satisfies the constraint.
+	Check(version Version) bool
+
+	// String returns the original representation of the constraint.
+	String() string
+}
+
+// VersionScheme parses versions and version constraints.
+type VersionScheme interface {
+
+	// ParseConstraint parses a version constraint.
+	ParseConstraint(constraint string) (VersionConstraint, error)
+
+	// ParseVersion parses a version.
+	ParseVersion(version string) (Version, error)
+}
+
+// VersionNormalizer transforms a raw version into a form that a VersionScheme can parse.
+type VersionNormalizer func(version string) string
+
+// SemverVersionScheme is a VersionScheme that parses versions and constraints with semver semantics.  Versions, and
+// each version in a constraint, are passed through each of the Normalizers, in order, before being parsed.
+type SemverVersionScheme struct {
+
+	// Normalizers are the normalizers applied to versions before parsing.
+	Normalizers []VersionNormalizer
+}
+
+var constraintVersionPattern = regexp.MustCompile(`[\w.*+-]+`)
+
+// ParseConstraint normalizes each version in and then parses a semver constraint.
+func (s SemverVersionScheme) ParseConstraint(constraint string) (VersionConstraint, error) {
+	n := constraint
+	if len(s.Normalizers) > 0 {
+		n = constraintVersionPattern.ReplaceAllStringFunc(constraint, s.normalize)
+	}
+
+	c, err := semver.NewConstraint(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return semverConstraint{constraint: c, raw: constraint}, nil
+}
+
+// ParseVersion normalizes and then parses a semver version.
+func (s SemverVersionScheme) ParseVersion(version string) (Version, error) {
+	v, err := semver.NewVersion(s.normalize(version))
+	if err != nil {
+		return nil, err
+	}
+
+	return semverVersion{version: v, raw: version}, nil
+}
+
+func (s SemverVersionScheme) normalize(version string) string {
+	for _, f := range s.Normalizers {
+		version = f(version)
+	}
+
+	return version
+}
+
+var (
+	javaVersionPattern        = regexp.MustCompile(`^1\.([\d]+)\.([\d]+)_([\d]+)(-[\w.]+)?$`)
+	partialJavaVersionPattern = regexp.MustCompile(`^1\.([5-8])(?:\.([\d]+|[xX*]))?$`)
+)
+
+// JavaVersionNormalizer normalizes legacy Java versions of the form 1.<major>.<minor>_<update> (e.g. 1.8.0_252) to
+// <major>.<minor>.<update> (e.g. 8.0.252) so that they order correctly with semver semantics.  A prerelease suffix
+// (e.g. 1.8.0_252-ea) is kept.  Partial legacy versions of Java 5 through 8 (e.g. 1.8.0, 1.8.*, or 1.8) are normalized
+// to the equivalent partial version (e.g. 8.0, 8.*, or 8) so that they can be used in constraints.  Other versions are
+// returned unchanged.
+func JavaVersionNormalizer(version string) string {
+	if g := javaVersionPattern.FindStringSubmatch(version); g != nil {
+		return fmt.Sprintf("%s.%s.%s%s", g[1], g[2], g[3], g[4])
+	}
+
+	if g := partialJavaVersionPattern.FindStringSubmatch(version); g != nil {
+		if g[2] != "" {
+			return fmt.Sprintf("%s.%s", g[1], g[2])
+		}
+		return g[1]
+	}
+
+	return version
+}
+
+var dateVersionPattern = regexp.MustCompile(`^(\d{4})-?(\d{2})-?(\d{2})(?:[.\-_]?(\d+))?$`)
+
+// DateVersionNormalizer normalizes date-stamped versions of the form YYYY-MM-DD, YYYYMMDD, and either followed by an
+// optional build number (e.g. 2020-05-01.2) to <YYYY>.<MM><DD>.<build> so that they order correctly with semver
+// semantics.  Other versions are returned unchanged.
+func DateVersionNormalizer(version string) string {
+	g := dateVersionPattern.FindStringSubmatch(version)
+	if g == nil {
+		return version
+	}
+
+	build := g[4]
+	if build == "" {
+		build = "0"
+	}
+
+	return fmt.Sprintf("%s.%s.%s", g[1], strings.TrimLeft(g[2]+g[3], "0"), build)
+}
+
+type semverConstraint struct {
+	constraint *semver.Constraints
+	raw        string
+}
+
+func (s semverConstraint) Check(version Version) bool {
+	v, ok := version.(semverVersion)
+	if !ok {
+		return false
+	}
+
+	return s.constraint.Check(v.version)
+}
+
+func (s semverConstraint) String() string {
+	return s.raw
+}
+
+type semverVersion struct {
+	version *semver.Version
+	raw     string
+}
+
+func (s semverVersion) Compare(other Version) int {
+	o, ok := other.(semverVersion)
+	if !ok {
+		panic(fmt.Sprintf("unable to compare semver version %s with %T", s.raw, other))
+	}
+
+	return s.version.Compare(o.version)
+}
+
+func (s semverVersion) Major() uint64 {
+	return s.version.Major()
+}
+
+func (s semverVersion) Minor() uint64 {
+	return s.version.Minor()
+}
+
+func (s semverVersion) Prerelease() string {
+	return s.version.Prerelease()
+}
+
+func (s semverVersion) Release() Version {
+	if s.version.Prerelease() == "" {
+		return s
+	}
+
+	v, _ := s.version.SetPrerelease("")
+
+	raw := v.String()
+	if i := strings.Index(s.raw, "-"+s.version.Prerelease()); i != -1 {
+		raw = s.raw[:i] + s.raw[i+1+len(s.version.Prerelease()):]
+	}
+
+	return semverVersion{version: &v, raw: raw}
+}
+
+func (s semverVersion) String() string {
+	return s.raw
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018-2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package libpak_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/sclevine/spec"
+)
+
+func testVersionScheme(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("SemverVersionScheme", func() {
+		var (
+			scheme libpak.SemverVersionScheme
+		)
+
+		it("compares versions", func() {
+			a, err := scheme.ParseVersion("1.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			b, err := scheme.ParseVersion("1.10.0")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(a.Compare(b)).To(Equal(-1))
+			Expect(b.Compare(a)).To(Equal(1))
+			Expect(a.Compare(a)).To(Equal(0))
+		})
+
+		it("checks constraints", func() {
+			c, err := scheme.ParseConstraint("1.*")
+			Expect(err).NotTo(HaveOccurred())
+
+			v, err := scheme.ParseVersion("1.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Check(v)).To(BeTrue())
+
+			v, err = scheme.ParseVersion("2.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Check(v)).To(BeFalse())
+		})
+
+		it("preserves original version", func() {
+			scheme.Normalizers = []libpak.VersionNormalizer{libpak.JavaVersionNormalizer}
+
+			v, err := scheme.ParseVersion("1.8.0_252")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.String()).To(Equal("1.8.0_252"))
+		})
+
+		it("normalizes constraints", func() {
+			scheme.Normalizers = []libpak.VersionNormalizer{libpak.JavaVersionNormalizer}
+
+			v, err := scheme.ParseVersion("1.8.0_252")
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, s := range []string{"1.8.0_252", "1.8.*", "8.*", ">= 1.8.0_200, < 1.8.0_300", "1.7.* || 1.8.*"} {
+				c, err := scheme.ParseConstraint(s)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.Check(v)).To(BeTrue(), s)
+				Expect(c.String()).To(Equal(s))
+			}
+
+			for _, s := range []string{"1.8.0_262", "1.7.*", "11.*"} {
+				c, err := scheme.ParseConstraint(s)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.Check(v)).To(BeFalse(), s)
+			}
+		})
+
+		it("panics when comparing with other versions", func() {
+			v, err := scheme.ParseVersion("1.1.0")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(func() { v.Compare(nil) }).To(Panic())
+		})
+
+		it("preserves original version of release", func() {
+			scheme.Normalizers = []libpak.VersionNormalizer{libpak.JavaVersionNormalizer}
+
+			v, err := scheme.ParseVersion("1.8.0_252-ea")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.Prerelease()).To(Equal("ea"))
+
+			r := v.Release()
+			Expect(r.Prerelease()).To(BeEmpty())
+			Expect(r.String()).To(Equal("1.8.0_252"))
+
+			v, err = scheme.ParseVersion("1.1.0-rc.1+build.2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.Release().String()).To(Equal("1.1.0+build.2"))
+		})
+
+		it("returns error for unparseable version", func() {
+			_, err := scheme.ParseVersion("test-version")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	it("normalizes Java versions", func() {
+		Expect(libpak.JavaVersionNormalizer("1.8.0_252")).To(Equal("8.0.252"))
+		Expect(libpak.JavaVersionNormalizer("1.8.0")).To(Equal("8.0"))
+		Expect(libpak.JavaVersionNormalizer("1.8.*")).To(Equal("8.*"))
+		Expect(libpak.JavaVersionNormalizer("1.8")).To(Equal("8"))
+		Expect(libpak.JavaVersionNormalizer("1.8.0_252-ea")).To(Equal("8.0.252-ea"))
+		Expect(libpak.JavaVersionNormalizer("11.0.8+10")).To(Equal("11.0.8+10"))
+		Expect(libpak.JavaVersionNormalizer("1.2.3")).To(Equal("1.2.3"))
+		Expect(libpak.JavaVersionNormalizer("1.10")).To(Equal("1.10"))
+	})
+
+	it("normalizes date versions", func() {
+		Expect(libpak.DateVersionNormalizer("2020-05-01")).To(Equal("2020.501.0"))
+		Expect(libpak.DateVersionNormalizer("20201231.2")).To(Equal("2020.1231.2"))
+		Expect(libpak.DateVersionNormalizer("1.1.1")).To(Equal("1.1.1"))
+	})
+}